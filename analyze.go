@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// svMaxCombinations is the number of share combinations sv can validate
+// (go-slip39's limit)
+const svMaxCombinations = 100
+
+var reMemberProbability = regexp.MustCompile(`^(\d+)\.(\d+)=(.+)$`)
+
+// recoverySet is a set of groups whose quorums can recover a secret
+type recoverySet struct {
+	groups       []int
+	shares       int
+	combinations *big.Int
+}
+
+// policyAnalysis is the recovery and risk analysis of a sharePolicy
+type policyAnalysis struct {
+	policy          *sharePolicy
+	loss            [][]float64 // per group, per member
+	compromise      [][]float64 // per group, per member
+	groupRecovery   []float64   // probability each group can still recover its share
+	groupCompromise []float64   // probability each group's share is compromised
+	recovery        float64     // probability the secret can be recovered
+	compromised     float64     // probability the secret is compromised
+	minShares       int         // fewest shares that can recover the secret
+	sets            []recoverySet
+	combinations    *big.Int        // number of combinations sv would check
+	critical        []shareLocation // shares whose loss alone prevents recovery
+}
+
+// shareLocation is the (1-based) group and member index of a share
+type shareLocation struct {
+	group  int
+	member int
+}
+
+// memberProbabilities returns def for every member of every group in
+// policy, overridden by any per-member probabilities from the policy file
+// (via field), and then by overrides, each of the form "G.M=PROB"
+func memberProbabilities(
+	policy *sharePolicy,
+	def float64,
+	field func(custodian) *float64,
+	overrides []string,
+) ([][]float64, error) {
+	if def < 0 || def > 1 {
+		return nil, fmt.Errorf("probabilities must be from 0 to 1, got %g", def)
+	}
+	probs := make([][]float64, len(policy.groups))
+	for g, pg := range policy.groups {
+		probs[g] = make([]float64, pg.count)
+		for m := range probs[g] {
+			probs[g][m] = def
+			if m < len(pg.custodians) {
+				if p := field(pg.custodians[m]); p != nil {
+					probs[g][m] = *p
+				}
+			}
+		}
+	}
+	for _, o := range overrides {
+		matches := reMemberProbability.FindStringSubmatch(o)
+		if matches == nil {
+			return nil, fmt.Errorf("invalid member probability %q: expected \"G.M=PROB\" e.g. \"2.1=0.2\"", o)
+		}
+		g, _ := strconv.Atoi(matches[1])
+		m, _ := strconv.Atoi(matches[2])
+		p, err := strconv.ParseFloat(matches[3], 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf("invalid member probability %q: probability must be from 0 to 1", o)
+		}
+		if g < 1 || g > len(probs) || m < 1 || m > len(probs[g-1]) {
+			return nil, fmt.Errorf("invalid member probability %q: the policy has no group %d member %d", o, g, m)
+		}
+		probs[g-1][m-1] = p
+	}
+	return probs, nil
+}
+
+// probAtLeast returns the probability that at least k of the independent
+// events with probabilities probs occur
+func probAtLeast(probs []float64, k int) float64 {
+	// dist[j] is the probability that exactly j events occur
+	dist := make([]float64, len(probs)+1)
+	dist[0] = 1
+	for i, p := range probs {
+		for j := i + 1; j > 0; j-- {
+			dist[j] = dist[j]*(1-p) + dist[j-1]*p
+		}
+		dist[0] *= 1 - p
+	}
+	total := 0.0
+	for j := k; j < len(dist); j++ {
+		total += dist[j]
+	}
+	return math.Min(total, 1)
+}
+
+// analyzePolicy analyzes policy, given the probabilities that each share is
+// lost and compromised
+func analyzePolicy(policy *sharePolicy, loss, compromise [][]float64) *policyAnalysis {
+	a := &policyAnalysis{
+		policy:       policy,
+		loss:         loss,
+		compromise:   compromise,
+		combinations: big.NewInt(0),
+	}
+
+	for g, pg := range policy.groups {
+		survive := make([]float64, pg.count)
+		for m, p := range loss[g] {
+			survive[m] = 1 - p
+		}
+		a.groupRecovery = append(a.groupRecovery, probAtLeast(survive, pg.threshold))
+		a.groupCompromise = append(a.groupCompromise, probAtLeast(compromise[g], pg.threshold))
+	}
+	a.recovery = probAtLeast(a.groupRecovery, policy.groupThreshold)
+	a.compromised = probAtLeast(a.groupCompromise, policy.groupThreshold)
+
+	// Every groupThreshold subset of groups is a way to recover the secret,
+	// needing a quorum from each group in it
+	a.minShares = -1
+	for _, groups := range indexCombinations(len(policy.groups), policy.groupThreshold) {
+		set := recoverySet{groups: groups, combinations: big.NewInt(1)}
+		for _, g := range groups {
+			pg := policy.groups[g]
+			set.shares += pg.threshold
+			set.combinations.Mul(set.combinations,
+				new(big.Int).Binomial(int64(pg.count), int64(pg.threshold)))
+		}
+		if a.minShares == -1 || set.shares < a.minShares {
+			a.minShares = set.shares
+		}
+		a.combinations.Add(a.combinations, set.combinations)
+		a.sets = append(a.sets, set)
+	}
+
+	// A share is critical if losing it alone leaves too few groups that can
+	// recover their share
+	for g, pg := range policy.groups {
+		if pg.count-1 >= pg.threshold || len(policy.groups)-1 >= policy.groupThreshold {
+			continue
+		}
+		for m := range pg.count {
+			a.critical = append(a.critical, shareLocation{group: g + 1, member: m + 1})
+		}
+	}
+
+	return a
+}
+
+// groupName describes group g (0-based) of a.policy e.g. "group 1 (family, 2of3)"
+func (a *policyAnalysis) groupName(g int) string {
+	pg := a.policy.groups[g]
+	if pg.name != "" {
+		return fmt.Sprintf("group %d (%s, %dof%d)", g+1, pg.name, pg.threshold, pg.count)
+	}
+	return fmt.Sprintf("group %d (%dof%d)", g+1, pg.threshold, pg.count)
+}
+
+// formatProbability formats p as a percentage, and also as "1 in N" if
+// p is small
+func formatProbability(p float64) string {
+	s := strconv.FormatFloat(p*100, 'g', 6, 64) + "%"
+	if p > 0 && p < 0.01 {
+		s += fmt.Sprintf(" (about 1 in %s)", formatThousands(int64(math.Round(1/p))))
+	}
+	return s
+}
+
+// formatThousands formats n with comma thousands separators
+func formatThousands(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// uniformProbability returns the probability shared by every member in
+// probs, and whether there is one
+func uniformProbability(probs [][]float64) (float64, bool) {
+	for _, group := range probs {
+		for _, p := range group {
+			if p != probs[0][0] {
+				return 0, false
+			}
+		}
+	}
+	return probs[0][0], true
+}
+
+// write outputs a report of a to w
+func (a *policyAnalysis) write(w io.Writer) {
+	bold := color.New(color.Bold)
+	shares := 0
+	for _, pg := range a.policy.groups {
+		shares += pg.count
+	}
+	fmt.Fprintf(w, "Policy: %s (%d shares in %d groups)\n\n", a.policy, shares, len(a.policy.groups))
+
+	bold.Fprintln(w, "Recovery sets")
+	fmt.Fprintf(w, "Minimum shares to recover: %d\n", a.minShares)
+	for _, set := range a.sets {
+		names := make([]string, len(set.groups))
+		for i, g := range set.groups {
+			names[i] = a.groupName(g)
+		}
+		plural := "s"
+		if set.combinations.Cmp(big.NewInt(1)) == 0 {
+			plural = ""
+		}
+		fmt.Fprintf(w, "  %s: %d shares, %s combination%s\n",
+			strings.Join(names, " + "), set.shares, set.combinations, plural)
+	}
+	fmt.Fprintf(w, "Combinations sv checks for a full set: %s", a.combinations)
+	switch {
+	case a.combinations.Cmp(big.NewInt(svMaxCombinations)) <= 0:
+		fmt.Fprintln(w)
+	case a.combinations.Cmp(big.NewInt(maxDiagnoseCombinations)) <= 0:
+		fmt.Fprintf(w, " %s more than sv's limit of %d - use sv --diagnose (up to %d)\n",
+			color.YellowString(warnGlyph), svMaxCombinations, maxDiagnoseCombinations)
+	default:
+		fmt.Fprintf(w, " %s more than sv's limit of %d, and sv --diagnose's %d - full sets can only be checked in parts\n",
+			color.YellowString(warnGlyph), svMaxCombinations, maxDiagnoseCombinations)
+	}
+	fmt.Fprintln(w)
+
+	bold.Fprintln(w, "Probabilities (assuming shares are lost or compromised independently)")
+	if p, ok := uniformProbability(a.loss); ok {
+		fmt.Fprintf(w, "Per-share loss probability: %s\n", formatProbability(p))
+	}
+	if p, ok := uniformProbability(a.compromise); ok {
+		fmt.Fprintf(w, "Per-share compromise probability: %s\n", formatProbability(p))
+	}
+	for g := range a.policy.groups {
+		fmt.Fprintf(w, "  %s: recoverable %s, compromised %s\n", a.groupName(g),
+			formatProbability(a.groupRecovery[g]), formatProbability(a.groupCompromise[g]))
+	}
+	fmt.Fprintf(w, "Recovery probability: %s\n", formatProbability(a.recovery))
+	fmt.Fprintf(w, "Loss probability (secret unrecoverable): %s\n", formatProbability(1-a.recovery))
+	fmt.Fprintf(w, "Compromise probability (secret exposed): %s\n", formatProbability(a.compromised))
+	fmt.Fprintln(w)
+
+	if len(a.critical) == 0 {
+		fmt.Fprintf(w, "%s No single share loss prevents recovery\n", color.GreenString(tickGlyph))
+		return
+	}
+	names := make([]string, len(a.critical))
+	for i, loc := range a.critical {
+		names[i] = fmt.Sprintf("group %d member %d", loc.group, loc.member)
+	}
+	fmt.Fprintf(w, "%s Single points of failure - losing any one of these shares prevents recovery: %s\n",
+		color.YellowString(warnGlyph), strings.Join(names, ", "))
+}
+
+func (cmd PolicyAnalyzeCmd) Run(ctx *Context) error {
+	policy, err := resolveSharePolicy(cmd.GroupThreshold, cmd.Groups, cmd.Policy, cmd.PolicyFile)
+	if err != nil {
+		return err
+	}
+	loss, err := memberProbabilities(policy, cmd.Loss,
+		func(c custodian) *float64 { return c.loss }, cmd.MemberLoss)
+	if err != nil {
+		return fmt.Errorf("loss: %w", err)
+	}
+	compromise, err := memberProbabilities(policy, cmd.Compromise,
+		func(c custodian) *float64 { return c.compromise }, cmd.MemberCompromise)
+	if err != nil {
+		return fmt.Errorf("compromise: %w", err)
+	}
+
+	analyzePolicy(policy, loss, compromise).write(ctx.writer)
+
+	return nil
+}
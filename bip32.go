@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	hardenedOffset = 0x80000000
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+// Extended key version bytes (BIP-32 and SLIP-132)
+var (
+	versionXprv = [4]byte{0x04, 0x88, 0xad, 0xe4}
+	versionXpub = [4]byte{0x04, 0x88, 0xb2, 0x1e}
+	versionYpub = [4]byte{0x04, 0x9d, 0x7c, 0xb2}
+	versionZpub = [4]byte{0x04, 0xb2, 0x47, 0x46}
+)
+
+// accountPaths are the standard single-sig account paths we report xpubs for,
+// with the SLIP-132 version bytes conventionally used for each
+var accountPaths = []struct {
+	path    string
+	version [4]byte
+}{
+	{"m/44'/0'/0'", versionXpub},
+	{"m/49'/0'/0'", versionYpub},
+	{"m/84'/0'/0'", versionZpub},
+	{"m/86'/0'/0'", versionXpub},
+}
+
+// extendedKey is a BIP-32 extended private key
+type extendedKey struct {
+	key         []byte
+	chainCode   []byte
+	depth       byte
+	parentFP    [4]byte
+	childNumber uint32
+}
+
+// newMasterKey derives a BIP-32 master key from seed
+func newMasterKey(seed []byte) (*extendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("invalid BIP32 seed length %d (must be 16-64 bytes)",
+			len(seed))
+	}
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	var k secp256k1.ModNScalar
+	defer k.Zero()
+	if overflow := k.SetByteSlice(sum[:32]); overflow || k.IsZero() {
+		wipeBytes(sum)
+		return nil, errors.New("invalid BIP32 master key (unusable seed)")
+	}
+
+	return &extendedKey{
+		key:       sum[:32],
+		chainCode: sum[32:],
+	}, nil
+}
+
+// publicKey returns the compressed public key for k
+func (k *extendedKey) publicKey() []byte {
+	return secp256k1.PrivKeyFromBytes(k.key).PubKey().SerializeCompressed()
+}
+
+// fingerprint returns the BIP-32 fingerprint of k (the first 4 bytes of the
+// hash160 of its public key)
+func (k *extendedKey) fingerprint() [4]byte {
+	var fp [4]byte
+	copy(fp[:], hash160(k.publicKey())[:4])
+	return fp
+}
+
+// child derives the child key of k with index i (hardened if i >= 2^31)
+func (k *extendedKey) child(i uint32) (*extendedKey, error) {
+	data := make([]byte, 0, 37)
+	if i >= hardenedOffset {
+		data = append(data, 0x00)
+		data = append(data, k.key...)
+	} else {
+		data = append(data, k.publicKey()...)
+	}
+	data = binary.BigEndian.AppendUint32(data, i)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	defer wipeBytes(data, sum[:32])
+
+	var il, parent secp256k1.ModNScalar
+	defer il.Zero()
+	defer parent.Zero()
+	if overflow := il.SetByteSlice(sum[:32]); overflow {
+		return nil, fmt.Errorf("invalid BIP32 child key %d", i)
+	}
+	parent.SetByteSlice(k.key)
+	il.Add(&parent)
+	if il.IsZero() {
+		return nil, fmt.Errorf("invalid BIP32 child key %d", i)
+	}
+	key := il.Bytes()
+	defer wipeBytes(key[:])
+
+	return &extendedKey{
+		key:         append([]byte{}, key[:]...),
+		chainCode:   sum[32:],
+		depth:       k.depth + 1,
+		parentFP:    k.fingerprint(),
+		childNumber: i,
+	}, nil
+}
+
+// derivePath derives the descendant of k given by path e.g. "m/84'/0'/0'"
+func (k *extendedKey) derivePath(path string) (*extendedKey, error) {
+	elts := strings.Split(path, "/")
+	if elts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q (must begin with \"m\")",
+			path)
+	}
+	key := k
+	for _, elt := range elts[1:] {
+		offset := uint32(0)
+		if strings.HasSuffix(elt, "'") || strings.HasSuffix(elt, "h") {
+			offset = hardenedOffset
+			elt = elt[:len(elt)-1]
+		}
+		var i uint32
+		if _, err := fmt.Sscanf(elt, "%d", &i); err != nil || i >= hardenedOffset {
+			return nil, fmt.Errorf("invalid derivation path element %q in %q",
+				elt, path)
+		}
+		child, err := key.child(i + offset)
+		if key != k {
+			key.wipe()
+		}
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	return key, nil
+}
+
+// wipe overwrites the private key material in k with zeroes
+func (k *extendedKey) wipe() {
+	wipeBytes(k.key, k.chainCode)
+}
+
+// serialize returns the base58check serialization of k using version, with
+// either the private or public key data as appropriate
+func (k *extendedKey) serialize(version [4]byte, private bool) string {
+	data := make([]byte, 0, 78)
+	data = append(data, version[:]...)
+	data = append(data, k.depth)
+	data = append(data, k.parentFP[:]...)
+	data = binary.BigEndian.AppendUint32(data, k.childNumber)
+	data = append(data, k.chainCode...)
+	if private {
+		data = append(data, 0x00)
+		data = append(data, k.key...)
+	} else {
+		data = append(data, k.publicKey()...)
+	}
+	return base58CheckEncode(data)
+}
+
+// String returns the xprv serialization of k
+func (k *extendedKey) String() string {
+	return k.serialize(versionXprv, true)
+}
+
+// xpub returns the public serialization of k using version
+func (k *extendedKey) xpub(version [4]byte) string {
+	return k.serialize(version, false)
+}
+
+func hash160(data []byte) []byte {
+	sha := sha256.Sum256(data)
+	h := ripemd160.New()
+	h.Write(sha[:])
+	return h.Sum(nil)
+}
+
+func base58CheckEncode(data []byte) string {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	data = append(data, second[:4]...)
+
+	var sb strings.Builder
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		sb.WriteByte(base58Alphabet[0])
+	}
+
+	digits := []byte{}
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		digits = append(digits, base58Alphabet[mod.Int64()])
+	}
+	for i := len(digits) - 1; i >= 0; i-- {
+		sb.WriteByte(digits[i])
+	}
+
+	return sb.String()
+}
+
+// bip32Report is the set of BIP-32 identifiers derived from a seed
+type bip32Report struct {
+	fingerprint string
+	xpubs       []string
+}
+
+// newBip32Report derives the master fingerprint and standard account xpubs
+// for seed
+func newBip32Report(seed []byte) (bip32Report, error) {
+	var report bip32Report
+	master, err := newMasterKey(seed)
+	if err != nil {
+		return report, err
+	}
+	defer master.wipe()
+	fp := master.fingerprint()
+	report.fingerprint = hex.EncodeToString(fp[:])
+
+	for _, ap := range accountPaths {
+		account, err := master.derivePath(ap.path)
+		if err != nil {
+			return report, err
+		}
+		report.xpubs = append(report.xpubs,
+			fmt.Sprintf("%s %s", ap.path, account.xpub(ap.version)))
+		account.wipe()
+	}
+
+	return report, nil
+}
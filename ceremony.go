@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/gavincarr/go-slip39"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// ceremonyEntropy returns bits of entropy from crypto/rand, mixed with any
+// dice rolls the user supplied. Rolls can only add entropy: the result is
+// the SHA-256 hash of the system randomness and the rolls, so it is no
+// weaker than the system randomness alone.
+func ceremonyEntropy(bits int, rolls string) ([]byte, error) {
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, fmt.Errorf("reading system randomness: %w", err)
+	}
+	if rolls == "" {
+		return entropy, nil
+	}
+
+	h := sha256.New()
+	h.Write(entropy)
+	h.Write([]byte(rolls))
+	sum := h.Sum(nil)
+	copy(entropy, sum)
+	wipeBytes(sum)
+	return entropy, nil
+}
+
+// parseDiceRolls returns the dice rolls (digits 1-6) in input, ignoring
+// whitespace, or an error if input contains anything else
+func parseDiceRolls(input string) (string, error) {
+	rolls := strings.Join(strings.Fields(input), "")
+	if strings.Trim(rolls, "123456") != "" {
+		return "", errors.New("dice rolls must be digits from 1 to 6")
+	}
+	return rolls, nil
+}
+
+// diceRollBits returns the bits of entropy contributed by n dice rolls
+func diceRollBits(n int) float64 {
+	return float64(n) * math.Log2(6)
+}
+
+func (cmd CeremonyCmd) Run(ctx *Context) error {
+	t := newTerminal(ctx)
+	bold := color.New(color.Bold)
+
+	bold.Fprintln(t.out, "seedkit SLIP-39 ceremony")
+	fmt.Fprint(t.out, `
+This guided flow generates a new BIP-39 mnemonic, splits it into SLIP-39
+shares, exhaustively validates them, displays each share one at a time to be
+recorded, and then asks you to re-enter each recorded share to verify it.
+No secrets are written to disk. Run this on an air-gapped live system like
+Tails (see seedkit doctor).
+
+`)
+
+	// Collect settings and entropy
+	wallet, err := t.readLine("Wallet name (to label shares with, optional): ")
+	if err != nil {
+		return err
+	}
+	words := 0
+	for {
+		words, err = t.readInt("Number of words in the BIP-39 mnemonic (12, 15, 18, 21, or 24)",
+			24, 12, 24)
+		if err != nil {
+			return err
+		}
+		if words%3 == 0 {
+			break
+		}
+		fmt.Fprintf(t.out, "%s please enter 12, 15, 18, 21, or 24\n",
+			color.YellowString("Warning:"))
+	}
+	bits := words / 3 * 32
+
+	rolls := ""
+	for {
+		line, err := t.readLine(fmt.Sprintf(
+			"Optional dice rolls to mix into system randomness (%d+ rolls for %d bits, blank to skip): ",
+			int(math.Ceil(float64(bits)/math.Log2(6))), bits))
+		if err != nil {
+			return err
+		}
+		rolls, err = parseDiceRolls(line)
+		if err == nil {
+			break
+		}
+		fmt.Fprintf(t.out, "%s %s\n", color.YellowString("Warning:"), err.Error())
+	}
+	if rolls != "" {
+		fmt.Fprintf(t.out, "Mixing in %d dice rolls (%.1f bits of entropy)\n",
+			len(rolls), diceRollBits(len(rolls)))
+	}
+
+	entropy, err := ceremonyEntropy(bits, rolls)
+	if err != nil {
+		return err
+	}
+	defer wipeBytes(entropy)
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return err
+	}
+
+	// Optionally display the mnemonic, e.g. to load into a wallet
+	show, err := t.confirm("Display the BIP-39 mnemonic (e.g. to load into a wallet)?", false)
+	if err != nil {
+		return err
+	}
+	if show {
+		if err := t.waitForEnter("Make sure no one else can see the screen, then press Enter..."); err != nil {
+			return err
+		}
+		if err := t.displayWords("BIP-39 mnemonic", mnemonic); err != nil {
+			return err
+		}
+	}
+
+	// Collect the share definition and generate shares
+	var groups []slip39.MemberGroupParameters
+	for {
+		line, err := t.readDefault("Share groups, as one or more MofN definitions (e.g. 2of3, or 2of3 3of5)",
+			"2of3")
+		if err != nil {
+			return err
+		}
+		groups, err = parseGroups(strings.Fields(line))
+		if err == nil {
+			break
+		}
+		fmt.Fprintf(t.out, "%s %s\n", color.YellowString("Warning:"), err.Error())
+	}
+	groupThreshold := 1
+	if len(groups) > 1 {
+		groupThreshold, err = t.readInt("Group threshold (number of groups required)",
+			1, 1, len(groups))
+		if err != nil {
+			return err
+		}
+	}
+
+	shareGroups, err := slip39.GenerateMnemonicsWithPassphrase(
+		groupThreshold, groups, entropy, nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Exhaustively validate all share combinations before displaying any
+	secret, combinations, err := shareGroups.ValidateMnemonicsWithPassphrase(nil)
+	if err != nil {
+		return fmt.Errorf("validating shares: %w", err)
+	}
+	valid := bytes.Equal(secret, entropy)
+	wipeBytes(secret)
+	if !valid {
+		return errors.New("validating shares: share combinations produced an unexpected secret")
+	}
+	fmt.Fprintf(t.out, "%s All SLIP-39 shares are %s - %d combinations produced the same mnemonic\n",
+		color.GreenString(tickGlyph), color.GreenString("good"), combinations)
+
+	// Display each share alone, and then verify each recorded share
+	displays := newShareDisplays(wallet, newSharePolicy(groupThreshold, groups), shareGroups)
+	for i, sd := range displays {
+		err := t.waitForEnter(fmt.Sprintf(
+			"Ready to display share %d of %d (%s) - make sure only its custodian can see the screen, then press Enter...",
+			i+1, len(displays), sd.title()))
+		if err != nil {
+			return err
+		}
+		if err := t.displayShare(sd, i+1, len(displays)); err != nil {
+			return err
+		}
+	}
+
+	// Each custodian re-enters their share in turn, so clear the screen
+	// between them, as for display
+	fmt.Fprintln(t.out, "Now re-enter each share from its recorded copy to verify it.")
+	for i, sd := range displays {
+		err := t.waitForEnter(fmt.Sprintf(
+			"Pass to the custodian of share %d of %d (%s) - make sure only they can see the screen, then press Enter...",
+			i+1, len(displays), sd.title()))
+		if err != nil {
+			return err
+		}
+		if err := t.retypeShare(sd, i+1, len(displays)); err != nil {
+			return err
+		}
+		t.clear()
+	}
+
+	fmt.Fprintf(t.out, "%s Ceremony %s - %d shares recorded and verified\n",
+		color.GreenString(tickGlyph), color.GreenString("complete"), len(displays))
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"runtime/debug"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+// deterministicSeedMinBytes is the minimum length of a --deterministic-seed
+const deterministicSeedMinBytes = 16
+
+// deterministicSeedDomain separates deterministic share keystreams from any
+// other use of the same seed
+const deterministicSeedDomain = "seedkit deterministic-seed v1"
+
+// slip39Module is the go-slip39 module path, for reporting its version
+const slip39Module = "github.com/gavincarr/go-slip39"
+
+// deterministicReader is an io.Reader returning a ChaCha20 keystream keyed
+// from a user-supplied seed, used in place of crypto/rand for reproducible
+// share generation
+type deterministicReader struct {
+	cipher *chacha20.Cipher
+}
+
+// newDeterministicReader returns a deterministicReader for seed
+func newDeterministicReader(seed []byte) (*deterministicReader, error) {
+	h := sha256.New()
+	h.Write([]byte(deterministicSeedDomain))
+	h.Write(seed)
+	key := h.Sum(nil)
+	defer wipeBytes(key)
+	nonce := make([]byte, chacha20.NonceSize)
+	cipher, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &deterministicReader{cipher: cipher}, nil
+}
+
+func (r *deterministicReader) Read(p []byte) (int, error) {
+	clear(p)
+	r.cipher.XORKeyStream(p, p)
+	return len(p), nil
+}
+
+// parseDeterministicSeed decodes a hex-encoded --deterministic-seed
+func parseDeterministicSeed(seedHex string) ([]byte, error) {
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --deterministic-seed: %w", err)
+	}
+	if len(seed) < deterministicSeedMinBytes {
+		wipeBytes(seed)
+		return nil, fmt.Errorf("invalid --deterministic-seed: must be at least %d bits, got %d",
+			deterministicSeedMinBytes*8, len(seed)*8)
+	}
+	return seed, nil
+}
+
+// withDeterministicRand runs fn with crypto/rand.Reader replaced by a
+// deterministicReader for seed, so that everything fn generates using
+// crypto/rand (e.g. SLIP39 identifiers, share polynomials, and digest
+// randomness) is derived from seed. crypto/rand.Reader is process-global, so
+// nothing else may use crypto/rand while fn runs.
+//
+// The output depends on exactly how (and in what order) seedkit and go-slip39
+// read random bytes, so is only reproducible with the same versions of both
+// (see deterministicVersions) - upgrading either may change it.
+func withDeterministicRand(seed []byte, fn func() error) error {
+	reader, err := newDeterministicReader(seed)
+	if err != nil {
+		return err
+	}
+	var saved io.Reader
+	saved, rand.Reader = rand.Reader, reader
+	defer func() { rand.Reader = saved }()
+	return fn()
+}
+
+// deterministicVersions returns the seedkit and go-slip39 versions needed to
+// reproduce deterministic output
+func deterministicVersions() string {
+	return fmt.Sprintf("seedkit %s, go-slip39 %s", version, moduleVersion(slip39Module))
+}
+
+// moduleVersion returns the version of the module path built into the
+// binary, including any replacement, or "unknown"
+func moduleVersion(path string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != path {
+			continue
+		}
+		v := dep.Version
+		if dep.Replace != nil {
+			v += " => " + dep.Replace.Path
+			if dep.Replace.Version != "" {
+				v += " " + dep.Replace.Version
+			}
+		}
+		return v
+	}
+	return "unknown"
+}
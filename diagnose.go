@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/gavincarr/go-slip39"
+)
+
+// maxDiagnoseCombinations caps the number of share combinations sv --diagnose
+// will try
+const maxDiagnoseCombinations = 10000
+
+// maxCrossChecks caps the number of quorums sb combines to cross-check a
+// superset of shares - larger supersets are sampled
+const maxCrossChecks = 100
+
+// diagnosedShare is an input share being diagnosed
+type diagnosedShare struct {
+	position int // 1-based input position
+	mnemonic string
+	share    slip39.Share
+	err      error // set if the share failed to parse
+}
+
+// name describes ds by its group and member index, and input position
+func (ds diagnosedShare) name() string {
+	if ds.err != nil {
+		return fmt.Sprintf("input share %d", ds.position)
+	}
+	return fmt.Sprintf("group %d member %d (input share %d)",
+		ds.share.GroupIndex+1, ds.share.MemberIndex+1, ds.position)
+}
+
+// diagnoseOutcome is the result of combining one quorum of shares
+type diagnoseOutcome struct {
+	shares []*diagnosedShare
+	secret int // index into shareDiagnosis.secrets, or -1 on error
+	err    error
+}
+
+// label describes the shares in o e.g. "group 1 members 1, 3 + group 2
+// members 2, 4"
+func (o diagnoseOutcome) label() string {
+	parts := []string{}
+	for i := 0; i < len(o.shares); {
+		group := o.shares[i].share.GroupIndex
+		members := []string{}
+		for ; i < len(o.shares) && o.shares[i].share.GroupIndex == group; i++ {
+			members = append(members, fmt.Sprint(o.shares[i].share.MemberIndex+1))
+		}
+		noun := "members"
+		if len(members) == 1 {
+			noun = "member"
+		}
+		parts = append(parts, fmt.Sprintf("group %d %s %s", group+1, noun, strings.Join(members, ", ")))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// shareDiagnosis is the result of diagnosing a share set
+type shareDiagnosis struct {
+	shares   []*diagnosedShare // shares that parsed, in input order
+	invalid  []*diagnosedShare // shares that failed to parse
+	foreign  []*diagnosedShare // shares with different set parameters to the majority
+	outcomes []diagnoseOutcome
+	secrets  [][]byte // distinct secrets produced
+	counts   []int    // number of outcomes producing each secret
+	majority int      // index of the most common secret, or -1 if none
+	suspects []*diagnosedShare
+	total    *big.Int // number of share combinations, of which outcomes may be a sample
+	sampled  bool     // set if outcomes are only a sample of the combinations
+}
+
+// diagnoseShares combines every quorum of shares in mnemonics (whose input
+// positions are in positions) and identifies the share(s) that only occur in
+// failed or minority combinations. Secrets are never output, only compared.
+// If sample is positive and there are more quorums than that, only sample
+// quorums (evenly spread through them all) are combined; otherwise there
+// must be at most maxDiagnoseCombinations quorums.
+func diagnoseShares(mnemonics []string, positions []int, passphrase []byte, sample int) (*shareDiagnosis, error) {
+	d := &shareDiagnosis{majority: -1, total: new(big.Int)}
+
+	// Parse shares, and find the set parameters shared by most of them
+	shares := []*diagnosedShare{}
+	paramCounts := map[slip39.ShareCommonParameters]int{}
+	for i, mnemonic := range mnemonics {
+		ds := &diagnosedShare{position: positions[i], mnemonic: mnemonic}
+		ds.share, ds.err = slip39.ParseShare(mnemonic)
+		if ds.err != nil {
+			d.invalid = append(d.invalid, ds)
+			continue
+		}
+		shares = append(shares, ds)
+		paramCounts[ds.share.ShareCommonParameters]++
+	}
+	d.shares = shares
+	if len(shares) == 0 {
+		return d, nil
+	}
+	var params slip39.ShareCommonParameters
+	for _, ds := range shares {
+		if paramCounts[ds.share.ShareCommonParameters] > paramCounts[params] {
+			params = ds.share.ShareCommonParameters
+		}
+	}
+
+	// Collate the remaining shares by group, and find each group's member
+	// threshold (again by majority)
+	groups := map[int][]*diagnosedShare{}
+	for _, ds := range shares {
+		if ds.share.ShareCommonParameters != params {
+			d.foreign = append(d.foreign, ds)
+			continue
+		}
+		groups[ds.share.GroupIndex] = append(groups[ds.share.GroupIndex], ds)
+	}
+	quorumGroups := []int{}
+	memberQuorums := map[int][][]*diagnosedShare{}
+	for g, members := range groups {
+		sort.SliceStable(members, func(i, j int) bool {
+			return members[i].share.MemberIndex < members[j].share.MemberIndex
+		})
+		thresholds := map[int]int{}
+		threshold := 0
+		for _, ds := range members {
+			thresholds[ds.share.MemberThreshold]++
+			if thresholds[ds.share.MemberThreshold] > thresholds[threshold] {
+				threshold = ds.share.MemberThreshold
+			}
+		}
+		if len(members) < threshold {
+			continue
+		}
+		for _, idx := range indexCombinations(len(members), threshold) {
+			quorum := make([]*diagnosedShare, len(idx))
+			for i, k := range idx {
+				quorum[i] = members[k]
+			}
+			memberQuorums[g] = append(memberQuorums[g], quorum)
+		}
+		quorumGroups = append(quorumGroups, g)
+	}
+	sort.Ints(quorumGroups)
+
+	// Count the combinations of group quorums that meet the group threshold
+	groupSets := [][]int{}
+	setCounts := []*big.Int{}
+	for _, gidx := range indexCombinations(len(quorumGroups), params.GroupThreshold) {
+		count := big.NewInt(1)
+		for i, k := range gidx {
+			gidx[i] = quorumGroups[k]
+			count.Mul(count, big.NewInt(int64(len(memberQuorums[gidx[i]]))))
+		}
+		groupSets = append(groupSets, gidx)
+		setCounts = append(setCounts, count)
+		d.total.Add(d.total, count)
+	}
+
+	// Choose the combinations to try, by index
+	indices := []*big.Int{}
+	switch {
+	case sample > 0 && d.total.Cmp(big.NewInt(int64(sample))) > 0:
+		d.sampled = true
+		for k := range sample {
+			i := new(big.Int).Mul(big.NewInt(int64(k)), d.total)
+			indices = append(indices, i.Div(i, big.NewInt(int64(sample))))
+		}
+	case d.total.Cmp(big.NewInt(maxDiagnoseCombinations)) > 0:
+		return nil, fmt.Errorf("too many share combinations to diagnose (%s, more than %d)",
+			d.total, maxDiagnoseCombinations)
+	default:
+		for i := range d.total.Int64() {
+			indices = append(indices, big.NewInt(i))
+		}
+	}
+	combinations := make([][]*diagnosedShare, len(indices))
+	for i, index := range indices {
+		combinations[i] = quorumCombination(index, groupSets, setCounts, memberQuorums)
+	}
+
+	// Combine each, and record which secret it produced
+	for _, combination := range combinations {
+		outcome := diagnoseOutcome{shares: combination, secret: -1}
+		mnemonics := make([]string, len(combination))
+		for i, ds := range combination {
+			mnemonics[i] = ds.mnemonic
+		}
+		secret, err := slip39.CombineMnemonicsWithPassphrase(mnemonics, passphrase)
+		if err != nil {
+			outcome.err = err
+		} else {
+			for i, s := range d.secrets {
+				if bytes.Equal(s, secret) {
+					outcome.secret = i
+					break
+				}
+			}
+			if outcome.secret == -1 {
+				outcome.secret = len(d.secrets)
+				d.secrets = append(d.secrets, secret)
+				d.counts = append(d.counts, 0)
+			} else {
+				wipeBytes(secret)
+			}
+			d.counts[outcome.secret]++
+		}
+		d.outcomes = append(d.outcomes, outcome)
+	}
+	for i, count := range d.counts {
+		if d.majority == -1 || count > d.counts[d.majority] {
+			d.majority = i
+		}
+	}
+
+	// Suspect shares are those only in failed or minority combinations
+	if d.majority != -1 {
+		inMajority := map[*diagnosedShare]bool{}
+		used := map[*diagnosedShare]bool{}
+		for _, o := range d.outcomes {
+			for _, ds := range o.shares {
+				used[ds] = true
+				if o.secret == d.majority {
+					inMajority[ds] = true
+				}
+			}
+		}
+		for _, ds := range shares {
+			if used[ds] && !inMajority[ds] {
+				d.suspects = append(d.suspects, ds)
+			}
+		}
+	}
+
+	return d, nil
+}
+
+// quorumCombination returns the combination of group quorums with the given
+// index, out of every combination of the member quorums in memberQuorums for
+// each of groupSets (with setCounts combinations each). Combinations are
+// ordered by group set, and then by each group's quorum, the last varying
+// fastest.
+func quorumCombination(
+	index *big.Int,
+	groupSets [][]int,
+	setCounts []*big.Int,
+	memberQuorums map[int][][]*diagnosedShare,
+) []*diagnosedShare {
+	i := new(big.Int).Set(index)
+	j := 0
+	for ; j < len(groupSets)-1 && i.Cmp(setCounts[j]) >= 0; j++ {
+		i.Sub(i, setCounts[j])
+	}
+	gidx := groupSets[j]
+	quorums := make([][]*diagnosedShare, len(gidx))
+	for k := len(gidx) - 1; k >= 0; k-- {
+		choices := memberQuorums[gidx[k]]
+		q := new(big.Int)
+		i.DivMod(i, big.NewInt(int64(len(choices))), q)
+		quorums[k] = choices[q.Int64()]
+	}
+	combination := []*diagnosedShare{}
+	for _, quorum := range quorums {
+		combination = append(combination, quorum...)
+	}
+	return combination
+}
+
+// wipe wipes the secrets in d
+func (d *shareDiagnosis) wipe() {
+	wipeBytes(d.secrets...)
+}
+
+// ok returns true if d found no problems
+func (d *shareDiagnosis) ok() bool {
+	return len(d.invalid) == 0 && len(d.foreign) == 0 && d.agrees()
+}
+
+// agrees returns true if every combination d tried produced the same secret
+func (d *shareDiagnosis) agrees() bool {
+	if len(d.secrets) != 1 {
+		return false
+	}
+	for _, o := range d.outcomes {
+		if o.err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ignored returns descriptions of the invalid and foreign shares in d, which
+// were left out of every combination
+func (d *shareDiagnosis) ignored() []string {
+	ignored := []string{}
+	for _, ds := range d.invalid {
+		ignored = append(ignored, fmt.Sprintf("%s - invalid: %s", ds.name(), ds.err.Error()))
+	}
+	for _, ds := range d.foreign {
+		ignored = append(ignored, fmt.Sprintf("%s - from a different share set (identifier %d)",
+			ds.name(), ds.share.Identifier))
+	}
+	return ignored
+}
+
+// bad returns the names of the shares d identifies as bad
+func (d *shareDiagnosis) bad() []string {
+	names := []string{}
+	for _, list := range [][]*diagnosedShare{d.invalid, d.foreign, d.suspects} {
+		for _, ds := range list {
+			names = append(names, ds.name())
+		}
+	}
+	return names
+}
+
+// write outputs a report of d to w
+func (d *shareDiagnosis) write(w io.Writer) {
+	for _, ds := range d.invalid {
+		fmt.Fprintf(w, "%s %s is invalid: %s\n", color.RedString(crossGlyph), ds.name(), ds.err.Error())
+	}
+	for _, ds := range d.foreign {
+		fmt.Fprintf(w, "%s %s is from a different share set (identifier %d), and was excluded\n",
+			color.RedString(crossGlyph), ds.name(), ds.share.Identifier)
+	}
+
+	fmt.Fprintf(w, "Tried %d share combinations:\n", len(d.outcomes))
+	failed := 0
+	for _, o := range d.outcomes {
+		switch {
+		case o.err != nil:
+			failed++
+			fmt.Fprintf(w, "  %s %s: %s\n", color.RedString(crossGlyph), o.label(), o.err.Error())
+		case o.secret == d.majority:
+			fmt.Fprintf(w, "  %s %s: secret %c\n", color.GreenString(tickGlyph), o.label(), 'A'+o.secret)
+		default:
+			fmt.Fprintf(w, "  %s %s: secret %c\n", color.RedString(crossGlyph), o.label(), 'A'+o.secret)
+		}
+	}
+
+	summary := []string{}
+	for i, count := range d.counts {
+		summary = append(summary, fmt.Sprintf("%d produced secret %c", count, 'A'+i))
+	}
+	if failed > 0 {
+		summary = append(summary, fmt.Sprintf("%d failed", failed))
+	}
+	if len(summary) > 0 {
+		fmt.Fprintf(w, "Summary: %s\n", strings.Join(summary, ", "))
+	}
+
+	for _, ds := range d.suspects {
+		fmt.Fprintf(w, "%s likely bad share: %s - it only appears in failed or minority combinations\n",
+			color.YellowString(warnGlyph), ds.name())
+	}
+}
+
+// result returns an error summarising the problems d found, or nil if none
+func (d *shareDiagnosis) result() error {
+	if d.ok() {
+		return nil
+	}
+	if bad := d.bad(); len(bad) > 0 {
+		return fmt.Errorf("share set disagrees - likely bad share(s): %s", strings.Join(bad, ", "))
+	}
+	if len(d.outcomes) == 0 {
+		return errors.New("share set disagrees - not enough shares to try any combinations")
+	}
+	return errors.New("share set disagrees - unable to identify the bad share(s)")
+}
+
+// combineQuorum combines mnemonics (whose input positions are in positions),
+// which may be more than a minimal set. Invalid shares, and shares from a
+// different share set, are ignored. The secret is recovered from the first
+// quorum of the remaining shares, and cross-checked against every other
+// quorum (or a sample of maxCrossChecks of them, for large supersets). If
+// there were surplus or ignored shares, the quorum used and the other shares
+// are reported to w. If any quorums disagree, the diagnosis is written to w,
+// and an error returned.
+func combineQuorum(w io.Writer, mnemonics []string, positions []int, passphrase []byte) ([]byte, error) {
+	d, err := diagnoseShares(mnemonics, positions, passphrase, maxCrossChecks)
+	if err != nil {
+		return nil, err
+	}
+	defer d.wipe()
+
+	ignored := d.ignored()
+	if len(d.outcomes) == 0 {
+		// With no quorum and nothing ignored, combine everything as given,
+		// so slip39 reports the problem
+		if len(ignored) == 0 {
+			return slip39.CombineMnemonicsWithPassphrase(mnemonics, passphrase)
+		}
+		return nil, fmt.Errorf("no quorum of valid shares remains after ignoring:\n  %s",
+			strings.Join(ignored, "\n  "))
+	}
+	if !d.agrees() {
+		d.write(w)
+		return nil, d.result()
+	}
+
+	used := d.outcomes[0].shares
+	if len(d.outcomes) == 1 && len(used) == len(mnemonics) {
+		return bytes.Clone(d.secrets[0]), nil
+	}
+	isUsed := map[*diagnosedShare]bool{}
+	names := []string{}
+	for _, ds := range used {
+		isUsed[ds] = true
+		names = append(names, ds.name())
+	}
+	surplus := []string{}
+	for _, ds := range d.shares {
+		if !isUsed[ds] && !slices.Contains(d.foreign, ds) {
+			surplus = append(surplus, ds.name())
+		}
+	}
+	fmt.Fprintf(w, "Used %d of %d shares: %s\n", len(used), len(mnemonics), strings.Join(names, ", "))
+	if len(surplus) > 0 {
+		fmt.Fprintf(w, "Surplus shares: %s\n", strings.Join(surplus, ", "))
+	}
+	if len(ignored) > 0 {
+		fmt.Fprintf(w, "%s Ignored shares:\n  %s\n", color.YellowString(warnGlyph), strings.Join(ignored, "\n  "))
+	}
+	if d.sampled {
+		fmt.Fprintf(w, "%s Cross-checked a sample of %d of the %s quorums - all produced the same secret\n",
+			color.YellowString(warnGlyph), len(d.outcomes), d.total)
+	} else {
+		fmt.Fprintf(w, "%s Cross-checked %d %s - all produced the same secret\n",
+			color.GreenString(tickGlyph), len(d.outcomes), plural(len(d.outcomes), "quorum"))
+	}
+
+	return bytes.Clone(d.secrets[0]), nil
+}
+
+// indexCombinations returns all k-element combinations of the indices 0..n-1
+func indexCombinations(n, k int) [][]int {
+	if k > n {
+		return nil
+	}
+	combinations := [][]int{}
+	idx := make([]int, k)
+	var rec func(start, depth int)
+	rec = func(start, depth int) {
+		if depth == k {
+			combinations = append(combinations, append([]int{}, idx...))
+			return
+		}
+		for i := start; i <= n-(k-depth); i++ {
+			idx[depth] = i
+			rec(i+1, depth+1)
+		}
+	}
+	rec(0, 0)
+	return combinations
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// Environment check statuses, in increasing order of severity
+const (
+	checkOK = iota
+	checkWarn
+	checkFail
+)
+
+// paranoidCommands are the commands (by full command path, for subcommands)
+// that handle secrets, which --paranoid refuses to run if any environment
+// check fails
+var paranoidCommands = map[string]bool{
+	"br": true, "bc": true, "bv": true, "bs": true, "be": true, "bl": true,
+	"sv": true, "sb": true, "sl": true, "ls": true, "lb": true, "sp": true,
+	"se": true, "eb": true, "es": true, "verify-transcript": true,
+	"verify-fingerprint": true, "ceremony": true, "policy explain": true,
+}
+
+// commandPath returns the command path from a kong command string, without
+// any positional arguments e.g. "policy explain" for "policy explain <shares>"
+func commandPath(command string) string {
+	names := []string{}
+	for _, f := range strings.Fields(command) {
+		if strings.HasPrefix(f, "<") {
+			break
+		}
+		names = append(names, f)
+	}
+	return strings.Join(names, " ")
+}
+
+// doctorCheck is the result of a single environment check
+type doctorCheck struct {
+	name   string
+	status int
+	detail string
+}
+
+// doctorEnv is the environment inspected by the doctor checks. root is
+// prepended to all system paths (e.g. /proc/swaps), for testing.
+type doctorEnv struct {
+	root      string
+	goos      string
+	stdoutTTY bool
+	coreLimit func() (uint64, error)
+}
+
+// newDoctorEnv returns the doctorEnv for the current process
+func newDoctorEnv() doctorEnv {
+	return doctorEnv{
+		root:      "/",
+		goos:      runtime.GOOS,
+		stdoutTTY: isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd()),
+		coreLimit: coreDumpLimit,
+	}
+}
+
+func (env doctorEnv) path(p string) string {
+	return filepath.Join(env.root, p)
+}
+
+// runDoctorChecks runs all the environment checks against env
+func runDoctorChecks(env doctorEnv) []doctorCheck {
+	if env.goos != "linux" {
+		return []doctorCheck{{
+			name:   "platform",
+			status: checkWarn,
+			detail: fmt.Sprintf("environment checks are only supported on linux, not %s", env.goos),
+		}, checkStdout(env)}
+	}
+	return []doctorCheck{
+		checkNetwork(env),
+		checkSwap(env),
+		checkCoreDumps(env),
+		checkStdout(env),
+		checkTails(env),
+	}
+}
+
+// checkNetwork fails if any non-loopback network interface is up
+func checkNetwork(env doctorEnv) doctorCheck {
+	check := doctorCheck{name: "network"}
+	entries, err := os.ReadDir(env.path("/sys/class/net"))
+	if err != nil {
+		check.status = checkWarn
+		check.detail = fmt.Sprintf("unable to list network interfaces: %s", err.Error())
+		return check
+	}
+	up := []string{}
+	for _, e := range entries {
+		iface := e.Name()
+		if iface == "lo" {
+			continue
+		}
+		operstate := readTrimmed(env.path(filepath.Join("/sys/class/net", iface, "operstate")))
+		carrier := readTrimmed(env.path(filepath.Join("/sys/class/net", iface, "carrier")))
+		if operstate == "up" || (operstate == "unknown" && carrier == "1") {
+			up = append(up, iface)
+		}
+	}
+	if len(up) > 0 {
+		check.status = checkFail
+		check.detail = fmt.Sprintf("network interfaces are up: %s (disconnect before handling secrets)",
+			strings.Join(up, ", "))
+		return check
+	}
+	check.detail = "no network interfaces are up"
+	return check
+}
+
+// checkSwap warns if any swap space is active
+func checkSwap(env doctorEnv) doctorCheck {
+	check := doctorCheck{name: "swap"}
+	data, err := os.ReadFile(env.path("/proc/swaps"))
+	if err != nil {
+		check.status = checkWarn
+		check.detail = fmt.Sprintf("unable to check swap: %s", err.Error())
+		return check
+	}
+	// The first line of /proc/swaps is a header
+	swaps := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n")[1:] {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			swaps = append(swaps, fields[0])
+		}
+	}
+	if len(swaps) > 0 {
+		check.status = checkWarn
+		check.detail = fmt.Sprintf("swap is active: %s (secrets may be written to disk)",
+			strings.Join(swaps, ", "))
+		return check
+	}
+	check.detail = "no swap is active"
+	return check
+}
+
+// checkCoreDumps warns if core dumps are enabled (seedkit disables them at
+// startup, so this only fails if that didn't work)
+func checkCoreDumps(env doctorEnv) doctorCheck {
+	check := doctorCheck{name: "core dumps"}
+	limit, err := env.coreLimit()
+	if err != nil {
+		check.status = checkWarn
+		check.detail = fmt.Sprintf("unable to check core dump limit: %s", err.Error())
+		return check
+	}
+	if limit != 0 {
+		check.status = checkWarn
+		check.detail = fmt.Sprintf("core dumps are enabled (limit %d bytes)", limit)
+		return check
+	}
+	check.detail = "core dumps are disabled"
+	return check
+}
+
+// checkStdout warns if stdout is not a terminal, since secrets would then
+// be written to a file or pipe
+func checkStdout(env doctorEnv) doctorCheck {
+	check := doctorCheck{name: "stdout"}
+	if !env.stdoutTTY {
+		check.status = checkWarn
+		check.detail = "stdout is not a terminal (secrets may be written to a file)"
+		return check
+	}
+	check.detail = "stdout is a terminal"
+	return check
+}
+
+// checkTails warns if we are not running in a Tails live session
+func checkTails(env doctorEnv) doctorCheck {
+	check := doctorCheck{name: "tails"}
+	osRelease := readTrimmed(env.path("/etc/os-release"))
+	for _, line := range strings.Split(osRelease, "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		value = strings.Trim(value, `"'`)
+		if (key == "NAME" || key == "TAILS_PRODUCT_NAME") && strings.EqualFold(value, "tails") {
+			check.detail = "running in a Tails live session"
+			return check
+		}
+	}
+	if _, err := os.Stat(env.path("/etc/amnesia")); err == nil {
+		check.detail = "running in a Tails live session"
+		return check
+	}
+	check.status = checkWarn
+	check.detail = "not running in a Tails live session"
+	return check
+}
+
+// readTrimmed returns the trimmed contents of filename, or "" on error
+func readTrimmed(filename string) string {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// writeDoctorChecks writes checks to w, skipping ok checks if quiet is true,
+// and returns the highest status
+func writeDoctorChecks(w io.Writer, checks []doctorCheck, quiet bool) int {
+	worst := checkOK
+	for _, check := range checks {
+		worst = max(worst, check.status)
+		glyph := color.GreenString(tickGlyph)
+		switch check.status {
+		case checkOK:
+			if quiet {
+				continue
+			}
+		case checkWarn:
+			glyph = color.YellowString(warnGlyph)
+		case checkFail:
+			glyph = color.RedString(crossGlyph)
+		}
+		fmt.Fprintf(w, "%s %s: %s\n", glyph, check.name, check.detail)
+	}
+	return worst
+}
+
+// paranoidCheck runs the environment checks before the secret-handling
+// command cmd, reporting any problems to w, and returns an error if any fail
+func paranoidCheck(w io.Writer, cmd string, env doctorEnv) error {
+	if !paranoidCommands[cmd] {
+		return nil
+	}
+	if writeDoctorChecks(w, runDoctorChecks(env), true) == checkFail {
+		return fmt.Errorf("--paranoid: refusing to run %q in an unsafe environment (see seedkit doctor)",
+			cmd)
+	}
+	return nil
+}
+
+func (cmd DoctorCmd) Run(ctx *Context) error {
+	checks := runDoctorChecks(newDoctorEnv())
+	switch writeDoctorChecks(ctx.writer, checks, false) {
+	case checkFail:
+		return errors.New("environment is not safe for handling secrets")
+	case checkWarn:
+		fmt.Fprintf(ctx.writer, "%s review the warnings above before handling secrets\n",
+			color.YellowString("Warning:"))
+	default:
+		fmt.Fprintf(ctx.writer, "%s Environment checks %s\n",
+			color.GreenString(tickGlyph), color.GreenString("passed"))
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// maxExplainRecoverySets is the number of ways to recover a secret that
+// policy explain lists before summarising the rest
+const maxExplainRecoverySets = 10
+
+// shareHolding is a (0-based) group and member index of a share held by a
+// custodian
+type shareHolding struct {
+	group  int
+	member int
+}
+
+// policyExplanation is a plain-language explanation of how a secret split
+// under policy can be recovered, for the holders of each share in holdings.
+// When built from shares rather than a policy, group thresholds and counts
+// that cannot be known from the shares are 0.
+type policyExplanation struct {
+	title    string
+	policy   *sharePolicy
+	holdings []shareHolding
+}
+
+// explainPolicy returns an explanation of policy for every member of it
+func explainPolicy(policy *sharePolicy) *policyExplanation {
+	title := "Recovery plan"
+	if policy.wallet != "" {
+		title += " for " + policy.wallet
+	}
+	e := &policyExplanation{
+		title:  fmt.Sprintf("%s: %s", title, policy),
+		policy: policy,
+	}
+	for g, pg := range policy.groups {
+		for m := range pg.count {
+			e.holdings = append(e.holdings, shareHolding{group: g, member: m})
+		}
+	}
+	return e
+}
+
+// explainShareSet returns an explanation of ss for the holder of each of its
+// shares, using only the (non-secret) share metadata
+func explainShareSet(ss *shareSet) *policyExplanation {
+	policy := &sharePolicy{
+		groupThreshold: ss.params.GroupThreshold,
+		groups:         make([]policyGroup, ss.params.GroupCount),
+	}
+	e := &policyExplanation{
+		title: fmt.Sprintf("Recovery plan for shares with identifier %d: %d of %d groups required",
+			ss.params.Identifier, ss.params.GroupThreshold, ss.params.GroupCount),
+		policy: policy,
+	}
+	for _, s := range ss.shares {
+		g := &policy.groups[s.GroupIndex]
+		g.threshold = s.MemberThreshold
+		// SLIP-39 only allows a member threshold of 1 with a single member
+		if s.MemberThreshold == 1 {
+			g.count = 1
+		}
+		e.holdings = append(e.holdings, shareHolding{group: s.GroupIndex, member: s.MemberIndex})
+	}
+	return e
+}
+
+// groupLabel returns a label for group g (0-based) e.g. "group 1 (family)"
+func (e *policyExplanation) groupLabel(g int) string {
+	if name := e.policy.groups[g].name; name != "" {
+		return fmt.Sprintf("group %d (%s)", g+1, name)
+	}
+	return fmt.Sprintf("group %d", g+1)
+}
+
+// quorum describes the shares needed from group g (0-based) e.g. "any 2 of
+// the 3 shares from group 1 (family)"
+func (e *policyExplanation) quorum(g int) string {
+	pg := e.policy.groups[g]
+	label := e.groupLabel(g)
+	switch {
+	case pg.threshold == 0:
+		return fmt.Sprintf("enough shares from %s (how many is not known, as none of its shares are present)", label)
+	case pg.threshold == 1 && pg.count == 1:
+		return fmt.Sprintf("the 1 share from %s", label)
+	case pg.count == 0:
+		return fmt.Sprintf("any %d shares from %s", pg.threshold, label)
+	case pg.threshold == pg.count:
+		return fmt.Sprintf("%s shares from %s", all(pg.count), label)
+	}
+	return fmt.Sprintf("any %d of the %d shares from %s", pg.threshold, pg.count, label)
+}
+
+// plural returns word, pluralised if n is not 1
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// all returns "both" or "all N"
+func all(n int) string {
+	if n == 2 {
+		return "both"
+	}
+	return fmt.Sprintf("all %d", n)
+}
+
+// joinOr joins items into a list like "a, b, or c"
+func joinOr(items []string) string {
+	if len(items) < 3 {
+		return strings.Join(items, " or ")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + ", or " + items[len(items)-1]
+}
+
+// writeOverview outputs how the secret can be recovered to w
+func (e *policyExplanation) writeOverview(w io.Writer) {
+	p := e.policy
+	ngroups := len(p.groups)
+	switch {
+	case ngroups == 1:
+		fmt.Fprintln(w, "The secret can be recovered with:")
+	case p.groupThreshold == ngroups:
+		fmt.Fprintf(w, "The secret is split between %d groups, and recovering it needs shares from %s of them:\n",
+			ngroups, all(ngroups))
+	case p.groupThreshold == 1:
+		fmt.Fprintf(w, "The secret is split between %d groups, and recovering it needs shares from any one of them:\n",
+			ngroups)
+	default:
+		fmt.Fprintf(w, "The secret is split between %d groups, and recovering it needs shares from any %d of them:\n",
+			ngroups, p.groupThreshold)
+	}
+
+	sets := indexCombinations(ngroups, p.groupThreshold)
+	for i, set := range sets {
+		if i == maxExplainRecoverySets {
+			fmt.Fprintf(w, "  ... or %d more combinations of %d groups\n", len(sets)-i, p.groupThreshold)
+			break
+		}
+		quorums := make([]string, len(set))
+		for j, g := range set {
+			quorums[j] = e.quorum(g)
+		}
+		prefix := "  - "
+		if i > 0 {
+			prefix = "  - or "
+		}
+		fmt.Fprintf(w, "%s%s\n", prefix, strings.Join(quorums, ", plus "))
+	}
+	fmt.Fprintln(w, "No one needs to reveal their share to anyone else until an agreed recovery.")
+}
+
+// writeInstructions outputs instructions for the holder of h to w
+func (e *policyExplanation) writeInstructions(w io.Writer, h shareHolding) {
+	p := e.policy
+	pg := p.groups[h.group]
+
+	holder := fmt.Sprintf("share %d", h.member+1)
+	if pg.count > 0 {
+		holder += fmt.Sprintf(" of %d", pg.count)
+	}
+	holder += " in " + e.groupLabel(h.group)
+	heading := strings.ToUpper(holder[:1]) + holder[1:]
+	if h.member < len(pg.custodians) {
+		heading = fmt.Sprintf("%s - %s", pg.custodians[h.member].name, holder)
+	}
+	color.New(color.Bold).Fprintln(w, heading)
+
+	// The shares needed from the holder's own group
+	var need string
+	switch {
+	case pg.threshold == 1:
+		need = "your share"
+	case pg.threshold == 2 && pg.count == 2:
+		need = "your share and the other share from " + e.groupLabel(h.group)
+	case pg.threshold == pg.count:
+		need = fmt.Sprintf("your share and the other %d shares from %s", pg.threshold-1, e.groupLabel(h.group))
+	default:
+		need = fmt.Sprintf("your share and any %d other %s from %s", pg.threshold-1,
+			plural(pg.threshold-1, "share"), e.groupLabel(h.group))
+	}
+
+	// Plus the shares needed from other groups
+	others := []string{}
+	for g := range p.groups {
+		if g != h.group {
+			others = append(others, e.quorum(g))
+		}
+	}
+	switch {
+	case p.groupThreshold == 1:
+	case p.groupThreshold-1 == len(others):
+		need += ", plus " + strings.Join(others, ", plus ")
+	case p.groupThreshold == 2:
+		need += ", plus " + joinOr(others)
+	default:
+		need += fmt.Sprintf(", plus shares from any %d of the other %d groups (see above)",
+			p.groupThreshold-1, len(others))
+	}
+	fmt.Fprintf(w, "  You hold %s. Recovering the secret needs %s.\n", holder, need)
+
+	if pg.threshold == 1 && p.groupThreshold == 1 {
+		fmt.Fprintf(w, "  %s Your share alone can recover the secret - guard it as closely as the wallet itself.\n",
+			color.YellowString(warnGlyph))
+	} else {
+		fmt.Fprintln(w, "  Your share alone cannot recover the secret, or reveal anything about it.")
+	}
+	fmt.Fprintln(w, "  Keep it private and offline, and only bring it out for an agreed recovery.")
+}
+
+// write outputs the explanation to w
+func (e *policyExplanation) write(w io.Writer) {
+	color.New(color.Bold).Fprintln(w, e.title)
+	fmt.Fprintln(w)
+	e.writeOverview(w)
+	for _, h := range e.holdings {
+		fmt.Fprintln(w)
+		e.writeInstructions(w, h)
+	}
+}
+
+func (cmd PolicyExplainCmd) Run(ctx *Context) error {
+	if len(cmd.Groups) > 0 || cmd.Policy != "" || cmd.PolicyFile != "" {
+		if len(cmd.Shares) > 0 || len(cmd.Files) > 0 {
+			return errors.New("give either a policy or shares to explain, not both")
+		}
+		policy, err := resolveSharePolicy(cmd.GroupThreshold, cmd.Groups, cmd.Policy, cmd.PolicyFile)
+		if err != nil {
+			return err
+		}
+		explainPolicy(policy).write(ctx.writer)
+		return nil
+	}
+
+	mnemonics, err := readShareMnemonics(ctx, cmd.Shares, cmd.Files)
+	if err != nil {
+		return err
+	}
+	mnemonics, positions, err := dedupeShares(ctx.stderr(), mnemonics)
+	if err != nil {
+		return err
+	}
+	sets, err := partitionShareSets(mnemonics, positions)
+	if err != nil {
+		return err
+	}
+	for i, set := range sets {
+		if i > 0 {
+			fmt.Fprintln(ctx.writer)
+		}
+		explainShareSet(set).write(ctx.writer)
+	}
+	return nil
+}
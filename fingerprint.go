@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/gavincarr/go-slip39"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	// fingerprintWords is the number of BIP39 words in a share fingerprint
+	// (11 bits each)
+	fingerprintWords = 4
+	// fingerprintDomain separates fingerprint hashes from any other use of
+	// a hash of a share
+	fingerprintDomain = "seedkit share fingerprint v1\n"
+)
+
+var reFingerprint = regexp.MustCompile(`(?i)^(\d+)[\s,:/-]*G(\d+)\s*M(\d+)[\s,:/-]+([a-z]+(?:[\s,-]+[a-z]+)*)$`)
+
+// shareFingerprint is a short, non-secret fingerprint of a SLIP39 share, for
+// checking a custodian still holds an intact share without them revealing it:
+// the share's identifier, (1-based) group and member indices, and a few words
+// derived from a hash of the share. The hash words are 44 bits, which is far
+// too few to help recover a share (at least 128 bits) or the secret.
+type shareFingerprint struct {
+	identifier int
+	group      int
+	member     int
+	words      []string
+}
+
+// String returns f in the form "28398 G1M2 word word word word"
+func (f shareFingerprint) String() string {
+	return fmt.Sprintf("%d G%dM%d %s", f.identifier, f.group, f.member, strings.Join(f.words, " "))
+}
+
+// fingerprintShare returns the fingerprint of the SLIP39 share mnemonic
+func fingerprintShare(mnemonic string) (shareFingerprint, error) {
+	s, err := slip39.ParseShare(mnemonic)
+	if err != nil {
+		return shareFingerprint{}, err
+	}
+	// Hash the canonical form, so the fingerprint doesn't depend on case or
+	// whitespace
+	canonical, err := s.Mnemonic()
+	if err != nil {
+		return shareFingerprint{}, err
+	}
+	hash := sha256.Sum256([]byte(fingerprintDomain + canonical))
+	defer wipeBytes(hash[:])
+
+	wordlist := bip39.GetWordList()
+	bits := new(big.Int).SetBytes(hash[:])
+	bits.Rsh(bits, uint(len(hash)*8-fingerprintWords*11))
+	words := make([]string, fingerprintWords)
+	mask := big.NewInt(2047)
+	for i := fingerprintWords - 1; i >= 0; i-- {
+		words[i] = wordlist[new(big.Int).And(bits, mask).Int64()]
+		bits.Rsh(bits, 11)
+	}
+
+	return shareFingerprint{
+		identifier: s.Identifier,
+		group:      s.GroupIndex + 1,
+		member:     s.MemberIndex + 1,
+		words:      words,
+	}, nil
+}
+
+// parseFingerprint parses a fingerprint like "28398 G1M2 word word word word",
+// ignoring case and punctuation between the parts
+func parseFingerprint(fingerprint string) (shareFingerprint, error) {
+	matches := reFingerprint.FindStringSubmatch(strings.TrimSpace(fingerprint))
+	if matches == nil {
+		return shareFingerprint{}, fmt.Errorf("invalid fingerprint %q: expected e.g. \"28398 G1M2 %s\"",
+			fingerprint, strings.Join(bip39.GetWordList()[:fingerprintWords], " "))
+	}
+	f := shareFingerprint{}
+	f.identifier, _ = strconv.Atoi(matches[1])
+	f.group, _ = strconv.Atoi(matches[2])
+	f.member, _ = strconv.Atoi(matches[3])
+	f.words = strings.FieldsFunc(strings.ToLower(matches[4]), func(r rune) bool {
+		return r == ',' || r == '-' || r == ' ' || r == '\t'
+	})
+	if len(f.words) != fingerprintWords {
+		return shareFingerprint{}, fmt.Errorf("invalid fingerprint %q: expected %d words, got %d",
+			fingerprint, fingerprintWords, len(f.words))
+	}
+	return f, nil
+}
+
+// writeShareFingerprints writes the fingerprint of each share in mnemonics
+// to w, followed by its title from titles, if given
+func writeShareFingerprints(w io.Writer, mnemonics []string, titles []string) error {
+	fmt.Fprintln(w, "Share fingerprints (not secret - check a share against its fingerprint with verify-fingerprint):")
+	for i, mnemonic := range mnemonics {
+		f, err := fingerprintShare(mnemonic)
+		if err != nil {
+			return err
+		}
+		if i < len(titles) {
+			fmt.Fprintf(w, "  %s  (%s)\n", f, titles[i])
+		} else {
+			fmt.Fprintf(w, "  %s\n", f)
+		}
+	}
+	return nil
+}
+
+func (cmd VerifyFingerprintCmd) Run(ctx *Context) error {
+	want, err := parseFingerprint(cmd.Fingerprint)
+	if err != nil {
+		return err
+	}
+	mnemonics, err := readShareMnemonics(ctx, cmd.Share, cmd.Files)
+	if err != nil {
+		return err
+	}
+	if len(mnemonics) != 1 {
+		return fmt.Errorf("expected a single share to verify, got %d", len(mnemonics))
+	}
+
+	got, err := fingerprintShare(mnemonics[0])
+	if err != nil {
+		return err
+	}
+	switch {
+	case got.String() == want.String():
+		fmt.Fprintf(ctx.writer, "%s Share matches fingerprint %s\n", color.GreenString(tickGlyph), got)
+		return nil
+	case got.identifier != want.identifier:
+		fmt.Fprintf(ctx.writer, "%s Share is from a different backup: identifier %d, not %d\n",
+			color.RedString(crossGlyph), got.identifier, want.identifier)
+	case got.group != want.group || got.member != want.member:
+		fmt.Fprintf(ctx.writer, "%s Share is a different share: group %d member %d, not group %d member %d\n",
+			color.RedString(crossGlyph), got.group, got.member, want.group, want.member)
+	default:
+		fmt.Fprintf(ctx.writer, "%s Share words do not match the fingerprint - the share may have been altered or mis-transcribed\n",
+			color.RedString(crossGlyph))
+	}
+	fmt.Fprintf(ctx.writer, "  share fingerprint: %s\n  given fingerprint: %s\n", got, want)
+	return errors.New("share does not match fingerprint")
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gavincarr/go-slip39"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	// Characters trimmed from the edges of input fields e.g. "1." or "(abandon)"
+	fieldTrimChars = `"'()[]{}<>.:!?`
+
+	// Labels are digits with optional short letter prefixes, in one or more
+	// segments e.g. 1, 01, 101, a01, 1-01, g1m2-07
+	labelPattern = `[a-z]{0,2}\d+(?:[a-z]{1,2}\d+)*(?:[-./:_][a-z]{0,2}\d+(?:[a-z]{1,2}\d+)*)*`
+)
+
+var (
+	reFieldSep     = regexp.MustCompile(`[\s,;]+`)
+	reWord         = regexp.MustCompile(`^[a-z]+$`)
+	reLabel        = regexp.MustCompile(`^` + labelPattern + `$`)
+	reLabelledWord = regexp.MustCompile(`^(` + labelPattern + `)[-.:)=]([a-z]+)$`)
+)
+
+// token is a single mnemonic word from user input, along with any label that
+// preceded it (e.g. "01" from "01 ALL" or "1" from "1. abandon"), and its
+// location in the input
+type token struct {
+	word  string
+	label string
+	line  int
+	field int
+}
+
+// location returns a description of where t was found in the input
+func (t token) location() string {
+	return fmt.Sprintf("line %d, field %d", t.line, t.field)
+}
+
+// tokenLine is the set of tokens found on a single input line. Lines are
+// grouped into blocks separated by one or more blank lines.
+type tokenLine struct {
+	line   int
+	block  int
+	tokens []token
+}
+
+// tokenizeInput splits input into lines of lowercased mnemonic word tokens.
+// It tolerates numbering and labels (e.g. "1. abandon", "01 ALL", "101,carpet"),
+// comma and semicolon separators, surrounding punctuation, and comments
+// (from "#" or "//" to the end of the line). Blank and comment-only lines are
+// skipped, but blank lines start a new block. Returns an error identifying the
+// first token it cannot interpret.
+func tokenizeInput(input string) ([]tokenLine, error) {
+	lines := []tokenLine{}
+	block := 0
+	for i, line := range strings.Split(strings.ToLower(input), "\n") {
+		lineno := i + 1
+		if strings.TrimSpace(line) == "" {
+			if len(lines) > 0 && lines[len(lines)-1].block == block {
+				block++
+			}
+			continue
+		}
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		if idx := strings.Index(line, "//"); idx >= 0 {
+			line = line[:idx]
+		}
+
+		tl := tokenLine{line: lineno, block: block}
+		labels := []string{}
+		labelField := 0
+		for j, field := range reFieldSep.Split(strings.TrimSpace(line), -1) {
+			field = strings.Trim(field, fieldTrimChars)
+			if field == "" {
+				continue
+			}
+			t := token{line: lineno, field: j + 1}
+			switch {
+			case reWord.MatchString(field):
+				t.word = field
+			case reLabelledWord.MatchString(field):
+				matches := reLabelledWord.FindStringSubmatch(field)
+				labels = append(labels, matches[1])
+				t.word = matches[2]
+			case reLabel.MatchString(field):
+				if len(labels) == 0 {
+					labelField = j + 1
+				}
+				labels = append(labels, field)
+				continue
+			default:
+				reason := "contains invalid characters"
+				if strings.ContainsAny(field, "0123456789") {
+					reason = "mixes digits into a word"
+				}
+				return nil, fmt.Errorf("line %d, field %d: rejected token %q (%s)",
+					lineno, j+1, field, reason)
+			}
+			t.label = strings.Join(labels, "-")
+			labels = labels[:0]
+			tl.tokens = append(tl.tokens, t)
+		}
+		if len(labels) > 0 {
+			return nil, fmt.Errorf("line %d, field %d: rejected label %q (no word follows it)",
+				lineno, labelField, strings.Join(labels, " "))
+		}
+		if len(tl.tokens) > 0 {
+			lines = append(lines, tl)
+		}
+	}
+	return lines, nil
+}
+
+// splitBlocks splits lines into slices of lines from the same block
+func splitBlocks(lines []tokenLine) [][]tokenLine {
+	blocks := [][]tokenLine{}
+	for i, tl := range lines {
+		if i == 0 || tl.block != lines[i-1].block {
+			blocks = append(blocks, []tokenLine{})
+		}
+		blocks[len(blocks)-1] = append(blocks[len(blocks)-1], tl)
+	}
+	return blocks
+}
+
+// flattenTokens returns the tokens from all of lines as a single slice
+func flattenTokens(lines []tokenLine) []token {
+	tokens := []token{}
+	for _, tl := range lines {
+		tokens = append(tokens, tl.tokens...)
+	}
+	return tokens
+}
+
+// joinTokenWords returns the words from tokens as a single space-separated string
+func joinTokenWords(tokens []token) string {
+	words := make([]string, len(tokens))
+	for i, t := range tokens {
+		words[i] = t.word
+	}
+	return strings.Join(words, " ")
+}
+
+// checkBip39Words returns an error identifying the first token in tokens
+// that is not in the BIP39 wordlist
+func checkBip39Words(tokens []token) error {
+	for _, t := range tokens {
+		if _, ok := bip39.GetWordIndex(t.word); !ok {
+			return fmt.Errorf("%s: rejected word %q (not in the BIP-39 wordlist)",
+				t.location(), t.word)
+		}
+	}
+	return nil
+}
+
+// checkSlip39Words returns an error identifying the first token in tokens
+// that is not in the SLIP39 wordlist
+func checkSlip39Words(tokens []token) error {
+	wordmap := slip39Wordmap()
+	for _, t := range tokens {
+		if _, ok := wordmap[t.word]; !ok {
+			return fmt.Errorf("%s: rejected word %q (not in the SLIP-39 wordlist)",
+				t.location(), t.word)
+		}
+	}
+	return nil
+}
+
+// slip39Wordmap returns a map of SLIP39 words to their wordlist indices.
+// go-slip39 doesn't export its wordlist, so we recover it by encoding a share
+// whose value words are the wordlist indices 0-1023 in order.
+var slip39Wordmap = sync.OnceValue(func() map[string]int {
+	const wordCount = 1024
+	value := new(big.Int)
+	for i := range wordCount {
+		value.Lsh(value, 10)
+		value.Or(value, big.NewInt(int64(i)))
+	}
+	share := slip39.Share{
+		ShareGroupParameters: slip39.ShareGroupParameters{
+			ShareCommonParameters: slip39.ShareCommonParameters{
+				GroupThreshold: 1,
+				GroupCount:     1,
+			},
+			MemberThreshold: 1,
+		},
+		ShareValues: value.FillBytes(make([]byte, wordCount*10/8)),
+	}
+	words, err := share.Words()
+	if err != nil {
+		panic(fmt.Sprintf("recovering SLIP39 wordlist: %s", err.Error()))
+	}
+
+	// Value words follow the 4 identifier and share parameter words
+	wordmap := make(map[string]int, wordCount)
+	for i, w := range words[4 : 4+wordCount] {
+		wordmap[w] = i
+	}
+	return wordmap
+})
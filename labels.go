@@ -0,0 +1,524 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gavincarr/go-slip39"
+)
+
+// Label scheme template fields, which are group number, member (share)
+// number within the group, and word number, all 1-based
+const (
+	labelGroup  = 'g'
+	labelMember = 'm'
+	labelWord   = 'w'
+
+	// The default label scheme, which uses go-slip39's numeric labels for
+	// SLIP39 shares e.g. 101 or 1101
+	defaultLabelScheme = "numeric"
+)
+
+// labelSchemePresets are the named label schemes, as templates for BIP39
+// mnemonics, single-group SLIP39 shares, and multi-group SLIP39 shares
+var labelSchemePresets = map[string][3]string{
+	"numeric": {"{w:02}", "", ""},
+	"alpha":   {"{m:A}{w:02}", "{m:A}{w:02}", "{g}{m:A}{w:02}"},
+	"dash":    {"{m}-{w:02}", "{m}-{w:02}", "{g}-{m}-{w:02}"},
+	"gm":      {"G{g}M{m}-{w:02}", "G{g}M{m}-{w:02}", "G{g}M{m}-{w:02}"},
+}
+
+var reLabelField = regexp.MustCompile(`\{([gmw])(?::(0\d+|[aA]))?\}`)
+
+// labelPosition is the position of a labelled word within a set of
+// mnemonics (group and member are both 1 for a BIP39 mnemonic)
+type labelPosition struct {
+	group  int
+	member int
+	word   int
+}
+
+// labelField is a single template field in a labelTemplate
+type labelField struct {
+	kind  byte
+	width int
+	alpha bool
+	upper bool
+}
+
+// labelTemplate is a label scheme defined by a template like "G{g}M{m}-{w:02}",
+// where {g}, {m} and {w} are the group, member and word numbers. Fields may
+// specify a zero-padded width (e.g. {w:02}), or be rendered as letters,
+// uppercase with {m:A} (A, B, ... Z, AA, AB...), or lowercase with {m:a}.
+// Padded fields are parsed numerically, so "1" matches {w:02}, except where
+// the field directly follows another numeric field.
+type labelTemplate struct {
+	template string
+	literals []string // literals[i] precedes fields[i]; the last is trailing
+	fields   []labelField
+	re       *regexp.Regexp
+	numeric  int // label length, for go-slip39's numeric SLIP39 labels
+	// alternate is true if numeric labels use the alternate widths
+	alternate bool
+}
+
+// newLabelTemplate parses template into a labelTemplate, or returns an error
+func newLabelTemplate(template string) (*labelTemplate, error) {
+	lt := &labelTemplate{template: template}
+	var pattern strings.Builder
+	pos := 0
+	seen := map[byte]bool{}
+	for _, loc := range reLabelField.FindAllStringSubmatchIndex(template, -1) {
+		literal := template[pos:loc[0]]
+		if strings.ContainsAny(literal, "{}") {
+			return nil, fmt.Errorf("invalid label template %q - bad field near %q",
+				template, literal)
+		}
+		lt.literals = append(lt.literals, literal)
+		pattern.WriteString(regexp.QuoteMeta(strings.ToLower(literal)))
+
+		field := labelField{kind: template[loc[2]]}
+		if seen[field.kind] {
+			return nil, fmt.Errorf("invalid label template %q - repeated {%c} field",
+				template, field.kind)
+		}
+		seen[field.kind] = true
+		spec := ""
+		if loc[4] >= 0 {
+			spec = template[loc[4]:loc[5]]
+		}
+		switch {
+		case spec == "A" || spec == "a":
+			field.alpha = true
+			field.upper = spec == "A"
+			pattern.WriteString("([a-z]+)")
+		case spec != "":
+			field.width, _ = strconv.Atoi(spec[1:])
+			// Accept any number of digits (e.g. "1" for "01"), unless the
+			// field directly follows another numeric field
+			if literal == "" && len(lt.fields) > 0 && !lt.fields[len(lt.fields)-1].alpha {
+				pattern.WriteString(fmt.Sprintf(`(\d{%d,})`, field.width))
+			} else {
+				pattern.WriteString(`(\d+)`)
+			}
+		default:
+			pattern.WriteString(`(\d+)`)
+		}
+		lt.fields = append(lt.fields, field)
+		pos = loc[1]
+	}
+	trailing := template[pos:]
+	if strings.ContainsAny(trailing, "{}") {
+		return nil, fmt.Errorf("invalid label template %q - bad field near %q",
+			template, trailing)
+	}
+	lt.literals = append(lt.literals, trailing)
+	pattern.WriteString(regexp.QuoteMeta(strings.ToLower(trailing)))
+
+	if !seen[labelWord] {
+		return nil, fmt.Errorf("invalid label template %q - no {w} word field",
+			template)
+	}
+
+	// Input labels have surrounding punctuation trimmed by tokenizeInput,
+	// so we do the same to the pattern
+	re := pattern.String()
+	for trimmed := ""; trimmed != re; {
+		trimmed = re
+		for _, c := range fieldTrimChars {
+			q := regexp.QuoteMeta(string(c))
+			re = strings.TrimPrefix(strings.TrimSuffix(re, q), q)
+		}
+	}
+	lt.re = regexp.MustCompile("^" + re + "$")
+
+	return lt, nil
+}
+
+// newNumericLabelTemplate returns a labelTemplate for parsing go-slip39's
+// numeric SLIP39 labels of the given length (3-6 digits) e.g. 101 or 1101
+func newNumericLabelTemplate(length int) (*labelTemplate, error) {
+	if length < 3 || length > 6 {
+		return nil, fmt.Errorf("invalid numeric label length %d (must be 3-6 digits)",
+			length)
+	}
+	return &labelTemplate{template: defaultLabelScheme, numeric: length}, nil
+}
+
+// numericLabelFormats are the group, member and word field widths for
+// numeric SLIP39 labels of each length, as used by go-slip39 (4- and
+// 5-digit labels with a leading zero use the alternate widths)
+var numericLabelFormats = map[int][2][3]int{
+	3: {{0, 1, 2}, {0, 1, 2}},
+	4: {{1, 1, 2}, {0, 2, 2}},
+	5: {{1, 2, 2}, {2, 1, 2}},
+	6: {{2, 2, 2}, {2, 2, 2}},
+}
+
+// parseNumeric parses a numeric SLIP39 label. A label that gives a zero
+// field with the primary widths is retried with the alternate widths, since
+// go-slip39 writes e.g. member 10 of a single group as 1001.
+func (lt *labelTemplate) parseNumeric(label string) (labelPosition, error) {
+	if len(label) != lt.numeric || strings.Trim(label, "0123456789") != "" {
+		return labelPosition{group: 1, member: 1}, fmt.Errorf("label %q does not match label scheme %q (expected %d digits)",
+			label, lt.template, lt.numeric)
+	}
+	formats := numericLabelFormats[lt.numeric]
+	if lt.alternate || label[0] == '0' {
+		return parseNumericWidths(label, formats[1])
+	}
+	pos, err := parseNumericWidths(label, formats[0])
+	if err != nil && formats[1] != formats[0] {
+		if pos2, err2 := parseNumericWidths(label, formats[1]); err2 == nil {
+			return pos2, nil
+		}
+	}
+	return pos, err
+}
+
+// parseNumericWidths parses a numeric SLIP39 label with the given group,
+// member and word field widths
+func parseNumericWidths(label string, widths [3]int) (labelPosition, error) {
+	pos := labelPosition{group: 1, member: 1}
+	fields := [3]int{1, 1, 0}
+	offset := 0
+	for i, width := range widths {
+		if width == 0 {
+			continue
+		}
+		fields[i], _ = strconv.Atoi(label[offset : offset+width])
+		offset += width
+		if fields[i] == 0 {
+			return pos, fmt.Errorf("label %q has a zero {%c} field", label,
+				"gmw"[i])
+		}
+	}
+	pos.group, pos.member, pos.word = fields[0], fields[1], fields[2]
+	return pos, nil
+}
+
+// formatNumeric returns the numeric SLIP39 label for pos
+func (lt *labelTemplate) formatNumeric(pos labelPosition) string {
+	formats := numericLabelFormats[lt.numeric]
+	widths := formats[0]
+	if (widths[0] > 0 && pos.group >= pow10(widths[0])) || pos.member >= pow10(widths[1]) {
+		widths = formats[1]
+	}
+	var sb strings.Builder
+	for i, n := range []int{pos.group, pos.member, pos.word} {
+		if widths[i] > 0 {
+			fmt.Fprintf(&sb, "%0*d", widths[i], n)
+		}
+	}
+	return sb.String()
+}
+
+func pow10(n int) int {
+	p := 1
+	for range n {
+		p *= 10
+	}
+	return p
+}
+
+// has returns true if lt includes a field of the given kind
+func (lt *labelTemplate) has(kind byte) bool {
+	if lt.numeric > 0 {
+		return kind != labelGroup || lt.numeric > 3
+	}
+	for _, f := range lt.fields {
+		if f.kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// format returns the label for pos
+func (lt *labelTemplate) format(pos labelPosition) string {
+	if lt.numeric > 0 {
+		return lt.formatNumeric(pos)
+	}
+	var sb strings.Builder
+	for i, f := range lt.fields {
+		sb.WriteString(lt.literals[i])
+		n := pos.word
+		switch f.kind {
+		case labelGroup:
+			n = pos.group
+		case labelMember:
+			n = pos.member
+		}
+		if f.alpha {
+			sb.WriteString(formatLabelLetters(n, f.upper))
+		} else {
+			fmt.Fprintf(&sb, "%0*d", f.width, n)
+		}
+	}
+	sb.WriteString(lt.literals[len(lt.literals)-1])
+	return sb.String()
+}
+
+// parse returns the position represented by label, or an error. Fields
+// missing from lt default to 1.
+func (lt *labelTemplate) parse(label string) (labelPosition, error) {
+	if lt.numeric > 0 {
+		return lt.parseNumeric(label)
+	}
+	pos := labelPosition{group: 1, member: 1}
+	matches := lt.re.FindStringSubmatch(strings.ToLower(label))
+	if matches == nil {
+		return pos, fmt.Errorf("label %q does not match label scheme %q",
+			label, lt.template)
+	}
+	for i, f := range lt.fields {
+		var n int
+		if f.alpha {
+			n = parseLabelLetters(matches[i+1])
+		} else {
+			n, _ = strconv.Atoi(matches[i+1])
+		}
+		if n == 0 {
+			return pos, fmt.Errorf("label %q has a zero {%c} field", label, f.kind)
+		}
+		switch f.kind {
+		case labelGroup:
+			pos.group = n
+		case labelMember:
+			pos.member = n
+		default:
+			pos.word = n
+		}
+	}
+	return pos, nil
+}
+
+// formatLabelLetters converts n to bijective base-26 letters (1 => A, 26 => Z,
+// 27 => AA, etc.)
+func formatLabelLetters(n int, upper bool) string {
+	base := 'a'
+	if upper {
+		base = 'A'
+	}
+	letters := []rune{}
+	for n > 0 {
+		n--
+		letters = append([]rune{base + rune(n%26)}, letters...)
+		n /= 26
+	}
+	return string(letters)
+}
+
+// parseLabelLetters is the inverse of formatLabelLetters
+func parseLabelLetters(s string) int {
+	n := 0
+	for _, c := range strings.ToLower(s) {
+		n = n*26 + int(c-'a') + 1
+	}
+	return n
+}
+
+// resolveLabelScheme returns the labelTemplate for scheme (a preset name or
+// a template) for BIP39 mnemonics (if bip is true), or for a set of SLIP39
+// shares with the given number of groups. Returns nil for the default
+// numeric SLIP39 scheme, which is handled by go-slip39.
+func resolveLabelScheme(scheme string, bip bool, groupCount int) (*labelTemplate, error) {
+	template := scheme
+	if preset, ok := labelSchemePresets[scheme]; ok {
+		switch {
+		case bip:
+			template = preset[0]
+		case groupCount > 1:
+			template = preset[2]
+		default:
+			template = preset[1]
+		}
+		if template == "" {
+			return nil, nil
+		}
+	} else if !strings.Contains(scheme, "{") {
+		names := make([]string, 0, len(labelSchemePresets))
+		for name := range labelSchemePresets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown label scheme %q (must be one of %s, or a template like \"G{g}M{m}-{w:02}\")",
+			scheme, strings.Join(names, ", "))
+	}
+	return newLabelTemplate(template)
+}
+
+// formatLabelledShares formats shareGroups as labelled words using lt, one
+// per line
+func formatLabelledShares(shareGroups slip39.ShareGroups, lt *labelTemplate) (string, error) {
+	if len(shareGroups) > 1 && !lt.has(labelGroup) {
+		return "", fmt.Errorf("label scheme %q has no {g} field, but there are %d share groups",
+			lt.template, len(shareGroups))
+	}
+	var sb strings.Builder
+	for g, shares := range shareGroups {
+		if len(shares) > 1 && !lt.has(labelMember) {
+			return "", fmt.Errorf("label scheme %q has no {m} field, but group %d has %d shares",
+				lt.template, g+1, len(shares))
+		}
+		for m, share := range shares {
+			for w, word := range strings.Fields(share) {
+				pos := labelPosition{group: g + 1, member: m + 1, word: w + 1}
+				fmt.Fprintf(&sb, "%s %s\n", lt.format(pos), word)
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+// labelledMnemonic is a mnemonic assembled from labelled words
+type labelledMnemonic struct {
+	group      int
+	member     int
+	words      map[int]token
+	duplicates map[int][]token // additional tokens with an already-seen label
+}
+
+// collateLabelledWords parses the labels on tokens using lt, and returns the
+// resulting mnemonics ordered by group and member
+func collateLabelledWords(tokens []token, lt *labelTemplate) ([]*labelledMnemonic, error) {
+	mnemonics := []*labelledMnemonic{}
+	index := map[[2]int]*labelledMnemonic{}
+	for _, t := range tokens {
+		if t.label == "" {
+			return nil, fmt.Errorf("%s: word %q has no label", t.location(), t.word)
+		}
+		pos, err := lt.parse(t.label)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t.location(), err)
+		}
+		key := [2]int{pos.group, pos.member}
+		lm, ok := index[key]
+		if !ok {
+			lm = &labelledMnemonic{
+				group:      pos.group,
+				member:     pos.member,
+				words:      map[int]token{},
+				duplicates: map[int][]token{},
+			}
+			index[key] = lm
+			mnemonics = append(mnemonics, lm)
+		}
+		if _, seen := lm.words[pos.word]; seen {
+			lm.duplicates[pos.word] = append(lm.duplicates[pos.word], t)
+			continue
+		}
+		lm.words[pos.word] = t
+	}
+	sort.SliceStable(mnemonics, func(i, j int) bool {
+		if mnemonics[i].group != mnemonics[j].group {
+			return mnemonics[i].group < mnemonics[j].group
+		}
+		return mnemonics[i].member < mnemonics[j].member
+	})
+	return mnemonics, nil
+}
+
+// length returns the number of words in lm, taken from the highest word label
+func (lm *labelledMnemonic) length() int {
+	length := 0
+	for w := range lm.words {
+		length = max(length, w)
+	}
+	return length
+}
+
+// problems returns descriptions of any missing or duplicated labels in lm,
+// in label order, checking for words up to length
+func (lm *labelledMnemonic) problems(lt *labelTemplate, length int) []string {
+	problems := []string{}
+	for w := 1; w <= max(length, lm.length()); w++ {
+		label := lt.format(labelPosition{group: lm.group, member: lm.member, word: w})
+		t, ok := lm.words[w]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("missing word with label %q", label))
+			continue
+		}
+		for _, dup := range lm.duplicates[w] {
+			detail := "repeated"
+			if dup.word != t.word {
+				detail = fmt.Sprintf("%q vs %q", t.word, dup.word)
+			}
+			problems = append(problems, fmt.Sprintf(
+				"duplicate label %q on line %d and line %d (%s)",
+				label, t.line, dup.line, detail))
+		}
+	}
+	return problems
+}
+
+// mnemonic returns the words of lm in order, or an error if any labels are
+// missing or duplicated
+func (lm *labelledMnemonic) mnemonic(lt *labelTemplate) (string, error) {
+	if problems := lm.problems(lt, lm.length()); len(problems) > 0 {
+		return "", errors.New(strings.Join(problems, "; "))
+	}
+	words := make([]string, lm.length())
+	for i := range words {
+		words[i] = lm.words[i+1].word
+	}
+	return strings.Join(words, " "), nil
+}
+
+// name returns a description of lm for use in messages e.g. "share 2", or
+// "group 1 share 2" if groups is true
+func (lm *labelledMnemonic) name(groups bool) string {
+	if groups {
+		return fmt.Sprintf("group %d share %d", lm.group, lm.member)
+	}
+	return fmt.Sprintf("share %d", lm.member)
+}
+
+// resolveInputLabelScheme returns the labelTemplate for scheme to use to
+// parse labelled SLIP39 words from tokens. For presets, it uses the multi-group
+// form if it matches the first label, and the single-group form otherwise.
+// Numeric labels are interpreted based on the length of the first label.
+func resolveInputLabelScheme(scheme string, tokens []token) (*labelTemplate, error) {
+	multi, err := resolveLabelScheme(scheme, false, 2)
+	if err != nil {
+		return nil, err
+	}
+	if multi == nil {
+		if len(tokens) == 0 {
+			return newNumericLabelTemplate(3)
+		}
+		lt, err := newNumericLabelTemplate(len(tokens[0].label))
+		if err != nil {
+			return nil, err
+		}
+		// go-slip39 uses the same widths for every label in a set, and the
+		// alternate widths always give some labels a leading zero (e.g. 0101
+		// for member 1 of a single group with more than 9 members), so 1101
+		// is then member 11, not group 1 member 1
+		for _, t := range tokens {
+			if len(t.label) == lt.numeric && t.label[0] == '0' {
+				lt.alternate = true
+				break
+			}
+		}
+		return lt, nil
+	}
+	if len(tokens) > 0 {
+		if _, err := multi.parse(tokens[0].label); err == nil {
+			return multi, nil
+		}
+	}
+	return resolveLabelScheme(scheme, false, 1)
+}
+
+// labelSchemeOrDefault returns scheme, or defaultLabelScheme if scheme is unset
+func labelSchemeOrDefault(scheme string) string {
+	if scheme == "" {
+		return defaultLabelScheme
+	}
+	return scheme
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"bufio"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -11,9 +11,9 @@ import (
 	"log/slog"
 	"math"
 	"math/big"
-	"math/rand"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -27,6 +27,13 @@ import (
 const (
 	GroupLimit = 16
 	tickGlyph  = "✔"
+	crossGlyph = "✘"
+	warnGlyph  = "⚠"
+
+	// SLIP39 shares have 7 words of metadata (identifier/exponent, group and
+	// member parameters, and checksum), plus 10 bits per word of share value
+	slip39MetadataWords = 7
+	slip39MinSecretBits = 128
 )
 
 var version = "undefined"
@@ -37,28 +44,45 @@ var (
 )
 
 var cli struct {
-	Verbose      int             `flag type:"counter" short:"v" help:"Enable verbose mode"`
-	BipRandom    BipRandomCmd    `cmd name:"br" help:"Generate a random BIP39 mnemonic seed phrase (TESTING ONLY)" hidden:"yes"`
-	BipCheckword BipCheckwordCmd `cmd name:"bc" help:"Generate one or more final checksum words for a BIP39 partial mnemonic"`
-	BipVal       BipValCmd       `cmd name:"bv" help:"Validate a BIP39 mnemonic seed phrase"`
-	BipSlip      BipSlipCmd      `cmd name:"bs" help:"Convert a BIP39 mnemonic seed to a set of SLIP39 shares"`
-	BipEntropy   BipEntropyCmd   `cmd name:"be" help:"Convert a BIP39 mnemonic seed to a hex-encoded entropy string"`
-	BipLabel     BipLabelCmd     `cmd name:"bl" help:"Convert a full set of BIP39 mnemonic shares to labelled word format"`
-	SlipVal      SlipValCmd      `cmd name:"sv" help:"Validate a full set of SLIP39 mnemonic shares"`
-	SlipBip      SlipBipCmd      `cmd name:"sb" help:"Convert a minimal set of SLIP39 mnemonic shares to a BIP39 mnemonic seed"`
-	SlipLabel    SlipLabelCmd    `cmd name:"sl" help:"Convert a full set of SLIP39 mnemonic shares to labelled word format"`
-	LabelSlip    LabelSlipCmd    `cmd name:"ls" help:"Convert a labelled word set to a set of SLIP39 mnemonic shares"`
-	SlipParse    SlipParseCmd    `cmd name:"sp" help:"Parse one or more SLIP39 shares"`
-	SlipEntropy  SlipEntropyCmd  `cmd name:"se" help:"Convert the given SLIP39 shares to a hex-encoded entropy string"`
-	EntropyBip   EntropyBipCmd   `cmd name:"eb" help:"Convert a hex-encoded entropy string to a BIP39 mnemonic seed"`
-	EntropySlip  EntropySlipCmd  `cmd name:"es" help:"Convert a hex-encoded entropy string to a set of SLIP39 shares"`
-	Version      VersionCmd      `cmd help:"Show version information"`
+	Verbose           int                  `flag type:"counter" short:"v" help:"Enable verbose mode"`
+	Paranoid          bool                 `flag help:"Check the environment (see doctor) before handling secrets, and refuse to run if it is unsafe"`
+	BipRandom         BipRandomCmd         `cmd name:"br" help:"Generate a random BIP39 mnemonic seed phrase (TESTING ONLY)" hidden:"yes"`
+	BipCheckword      BipCheckwordCmd      `cmd name:"bc" help:"Generate one or more final checksum words for a BIP39 partial mnemonic"`
+	BipVal            BipValCmd            `cmd name:"bv" help:"Validate a BIP39 mnemonic seed phrase"`
+	BipSlip           BipSlipCmd           `cmd name:"bs" help:"Convert a BIP39 mnemonic seed to a set of SLIP39 shares"`
+	BipEntropy        BipEntropyCmd        `cmd name:"be" help:"Convert a BIP39 mnemonic seed to a hex-encoded entropy string"`
+	BipLabel          BipLabelCmd          `cmd name:"bl" help:"Convert a full set of BIP39 mnemonic shares to labelled word format"`
+	SlipVal           SlipValCmd           `cmd name:"sv" help:"Validate a full set of SLIP39 mnemonic shares"`
+	SlipBip           SlipBipCmd           `cmd name:"sb" help:"Convert a set of SLIP39 mnemonic shares (minimal, or a superset) to a BIP39 mnemonic seed"`
+	SlipLabel         SlipLabelCmd         `cmd name:"sl" help:"Convert a full set of SLIP39 mnemonic shares to labelled word format"`
+	LabelSlip         LabelSlipCmd         `cmd name:"ls" help:"Convert a labelled word set to a set of SLIP39 mnemonic shares"`
+	LabelBip          LabelBipCmd          `cmd name:"lb" help:"Convert a labelled word set to a BIP39 mnemonic seed"`
+	SlipParse         SlipParseCmd         `cmd name:"sp" help:"Parse one or more SLIP39 shares"`
+	SlipEntropy       SlipEntropyCmd       `cmd name:"se" help:"Convert the given SLIP39 shares to a hex-encoded entropy string"`
+	EntropyBip        EntropyBipCmd        `cmd name:"eb" help:"Convert a hex-encoded entropy string to a BIP39 mnemonic seed"`
+	EntropySlip       EntropySlipCmd       `cmd name:"es" help:"Convert a hex-encoded entropy string to a set of SLIP39 shares"`
+	VerifyTranscript  VerifyTranscriptCmd  `cmd name:"verify-transcript" help:"Verify a transcript (plain or labelled) against the original BIP39 mnemonic or SLIP39 shares"`
+	VerifyFingerprint VerifyFingerprintCmd `cmd name:"verify-fingerprint" help:"Verify a SLIP39 share against its fingerprint (from bs, sp, or sl), without revealing the share"`
+	Policy            PolicyCmd            `cmd help:"Analyze a SLIP39 sharing policy (no secrets required)"`
+	Ceremony          CeremonyCmd          `cmd help:"Run an interactive guided ceremony to generate, share, record, and verify a new seed"`
+	Doctor            DoctorCmd            `cmd help:"Check the environment is safe for handling secrets (network, swap, core dumps, terminal, Tails)"`
+	Version           VersionCmd           `cmd help:"Show version information"`
 }
 
 type Context struct {
-	verbose int
-	reader  io.Reader
-	writer  io.Writer
+	verbose   int
+	reader    io.Reader
+	writer    io.Writer
+	errWriter io.Writer
+	decrypter *shareDecrypter
+}
+
+// stderr returns ctx.errWriter, or os.Stderr if unset
+func (ctx *Context) stderr() io.Writer {
+	if ctx.errWriter == nil {
+		return os.Stderr
+	}
+	return ctx.errWriter
 }
 
 type BipRandomCmd struct {
@@ -69,8 +93,9 @@ type BipCheckwordCmd struct {
 	Multi         bool `flag short:"m"  help:"output all valid mnemonics for the given partial seed, not just one" xor:"flags"`
 	Word          bool `flag short:"w" help:"output just the final checksum word(s), not the full mnemonic"`
 	Deterministic bool `flag short:"d"  help:"always use the first checksum word found (for testing)" xor:"flags"`
+	Choice        int  `flag short:"c" help:"choose the checksum word by its index in the list of valid checksum words (1-128 for 11 words, 64 for 14, 32 for 17, 16 for 20, or 8 for 23), e.g. from dice rolls, instead of randomly" xor:"flags"`
 
-	PartialMnemonic []string `arg help:"BIP39 partial mnemonic seed phrase (11 or 23 words)" optional`
+	PartialMnemonic []string `arg help:"BIP39 partial mnemonic seed phrase (11, 14, 17, 20, or 23 words)" optional`
 }
 
 type BipValCmd struct {
@@ -80,38 +105,65 @@ type BipValCmd struct {
 
 type BipSlipCmd struct {
 	GroupThreshold int      `flag short:"t" aliases:"threshold" help:"Group threshold (the number of groups required to combine)" default:"1"`
-	Groups         []string `flag short:"g" help:"Group definitions, as \"MofN\" strings e.g. 1of1, 2of4, 3of5, etc. (repeatable)" required`
+	Groups         []string `flag short:"g" help:"Group definitions, as \"MofN\" strings e.g. 1of1, 2of4, 3of5, etc. (repeatable)" xor:"policy" required`
+	Policy         string   `flag help:"Sharing policy with optionally named groups, instead of --groups and --group-threshold e.g. \"2 of [family:2of3, lawyer:1of1, vault:3of5]\"" xor:"policy" required`
+	PolicyFile     string   `flag name:"policy-file" type:"existingfile" help:"YAML or JSON policy file with named groups, custodians, and optional per-share output files or encryption recipients" xor:"policy" required`
 	Passphrase     string   `flag short:"p" help:"passphrase to use for BIP39 seed and SLIP39 shares"`
+	OneAtATime     bool     `flag short:"1" name:"one-at-a-time" help:"display shares one at a time, clearing the screen and scrollback in between" xor:"output"`
+	Retype         bool     `flag short:"r" help:"with --one-at-a-time, require each share to be typed back before moving on"`
+	EncryptTo      []string `flag short:"e" name:"encrypt-to" sep:"none" help:"encrypt each share to a custodian, in share order: an age recipient (age1...), or a file with age recipients or an ASCII-armored OpenPGP public key (repeatable, one per share)" xor:"output"`
+	OutputDir      string   `flag short:"o" name:"output-dir" type:"existingdir" default:"." help:"directory to write encrypted share files, and relative policy file outputs, to"`
+	DetSeed        string   `flag name:"deterministic-seed" placeholder:"HEX" help:"derive all share randomness from this hex seed (128+ bits), so shares can be reproduced for audits (with the same seedkit and go-slip39 versions) - INSECURE: anyone with the seed and the BIP39 mnemonic can recreate every share"`
 
 	Seed []string `arg help:"BIP39 mnemonic seed phrase" optional`
 }
 
 type BipLabelCmd struct {
-	Upper bool `flag short:"u" help:"output words in uppercase"`
+	Upper  bool   `flag short:"u" help:"output words in uppercase"`
+	Labels string `flag short:"l" default:"numeric" help:"label scheme: numeric (101, or 01 for BIP39), alpha (A01), dash (1-01), gm (G1M2-07), or a template like \"G{g}M{m}-{w:02}\""`
 
 	Seed []string `arg help:"BIP39 mnemonic seed phrase" optional`
 }
 
 type SlipValCmd struct {
-	Passphrase string `flag short:"p" help:"passphrase used with the SLIP39 shares"`
-	CheckFile  string `flag short:"c" aliases:"cf" help:"check file with the source BIP39 mnemonic seed"`
+	Passphrase string   `flag short:"p" help:"passphrase used with the SLIP39 shares"`
+	CheckFile  string   `flag short:"c" aliases:"cf" help:"check file with the source BIP39 mnemonic seed"`
+	Files      []string `flag short:"f" name:"file" sep:"none" type:"existingfile" help:"file containing one or more SLIP39 share mnemonics, e.g. one per custodian (repeatable, and combined with any share arguments)"`
+	Diagnose   bool     `flag short:"d" help:"combine every quorum of shares separately, report each outcome, and identify any bad share(s)"`
+	Partition  bool     `flag name:"by-identifier" help:"partition the shares into sets by identifier (i.e. by backup), and process each set separately"`
 
 	Shares []string `arg help:"full set of SLIP39 share mnemonics (repeated quoted args, or one per line on stdin)" optional`
 }
 
 type SlipBipCmd struct {
-	Passphrase string `flag short:"p" help:"passphrase to use for BIP39 seed and SLIP39 shares"`
-
-	Shares []string `arg help:"minimal set of SLIP39 share mnemonics (repeated quoted args, or one per line on stdin)" optional`
+	Passphrase string   `flag short:"p" help:"passphrase to use for BIP39 seed and SLIP39 shares"`
+	Native     bool     `flag short:"n" help:"output the BIP32 master fingerprint and account xpubs derived natively from the SLIP39 master secret (Trezor-style), instead of a BIP39 mnemonic" xor:"output"`
+	Hex        bool     `flag short:"x" help:"output the hex-encoded master secret, instead of a BIP39 mnemonic (required for secrets with no BIP39 equivalent)" xor:"output"`
+	Files      []string `flag short:"f" name:"file" sep:"none" type:"existingfile" help:"file containing one or more SLIP39 share mnemonics, e.g. one per custodian (repeatable, and combined with any share arguments)"`
+	Identities []string `flag short:"i" name:"identity" sep:"none" type:"existingfile" help:"age identity file or ASCII-armored OpenPGP secret key file, used to decrypt encrypted share files (repeatable)"`
+	KeyPass    string   `flag name:"key-passphrase" help:"passphrase for passphrase-protected OpenPGP secret keys"`
+	Partition  bool     `flag name:"by-identifier" help:"partition the shares into sets by identifier (i.e. by backup), and process each set separately"`
+
+	Shares []string `arg help:"SLIP39 share mnemonics - a minimal set, or more to cross-check (repeated quoted args, or one per line on stdin)" optional`
 }
 
 type SlipLabelCmd struct {
-	Upper bool `flag short:"u" help:"output words in uppercase"`
+	Upper      bool     `flag short:"u" help:"output words in uppercase"`
+	Labels     string   `flag short:"l" default:"numeric" help:"label scheme: numeric (101, or 01 for BIP39), alpha (A01), dash (1-01), gm (G1M2-07), or a template like \"G{g}M{m}-{w:02}\""`
+	Files      []string `flag short:"f" name:"file" sep:"none" type:"existingfile" help:"file containing one or more SLIP39 share mnemonics, e.g. one per custodian (repeatable, and combined with any share arguments)"`
+	Partition  bool     `flag name:"by-identifier" help:"partition the shares into sets by identifier (i.e. by backup), and process each set separately"`
+	Policy     string   `flag help:"sharing policy the shares were generated with (see bs --policy), to output a handoff sheet per share with its group name" xor:"policy"`
+	PolicyFile string   `flag name:"policy-file" type:"existingfile" help:"policy file the shares were generated with (see bs --policy-file), to output a handoff sheet per custodian" xor:"policy"`
 
 	Shares []string `arg help:"minimal set of SLIP39 share mnemonics (repeated quoted args, or one per line on stdin)" optional`
 }
 
 type LabelSlipCmd struct {
+	Labels string `flag short:"l" default:"numeric" help:"label scheme: numeric (101, or 01 for BIP39), alpha (A01), dash (1-01), gm (G1M2-07), or a template like \"G{g}M{m}-{w:02}\""`
+}
+
+type LabelBipCmd struct {
+	Labels string `flag short:"l" default:"numeric" help:"label scheme: numeric (101, or 01 for BIP39), alpha (A01), dash (1-01), gm (G1M2-07), or a template like \"G{g}M{m}-{w:02}\""`
 }
 
 type BipEntropyCmd struct {
@@ -123,18 +175,70 @@ type EntropyBipCmd struct {
 }
 
 type SlipEntropyCmd struct {
+	Files []string `flag short:"f" name:"file" sep:"none" type:"existingfile" help:"file containing one or more SLIP39 share mnemonics, e.g. one per custodian (repeatable, and combined with any share arguments)"`
+
 	Shares []string `arg help:"SLIP39 share mnemonics (repeated quoted args, or one per line on stdin)" optional`
 }
 
 type EntropySlipCmd struct {
-	Entropy string   `arg help:"Hex-encoded entropy string" required`
+	GroupThreshold int    `flag short:"t" help:"Group threshold (the number of groups required to combine)" default:"1"`
+	Passphrase     string `flag short:"p" help:"passphrase to use for SLIP39 shares"`
+
+	Entropy string   `arg help:"Hex-encoded entropy string (at least 128 bits, and a multiple of 16 bits)" required`
 	Groups  []string `arg help:"Group definitions, as \"MofN\" strings e.g. 2of4, 3of5, etc." required`
 }
 
 type SlipParseCmd struct {
+	Files []string `flag short:"f" name:"file" sep:"none" type:"existingfile" help:"file containing one or more SLIP39 share mnemonics, e.g. one per custodian (repeatable, and combined with any share arguments)"`
+
 	Shares []string `arg help:"SLIP39 share mnemonics (repeated quoted args, or one per line on stdin)" optional`
 }
 
+type VerifyTranscriptCmd struct {
+	Labels     string `flag short:"l" default:"numeric" help:"label scheme for labelled transcripts (see sl --help)"`
+	Original   string `arg type:"existingfile" help:"file containing the original BIP39 mnemonic or SLIP39 shares"`
+	Transcript string `arg optional type:"existingfile" help:"file containing the transcript to verify (default: stdin)"`
+}
+
+type PolicyCmd struct {
+	Analyze PolicyAnalyzeCmd `cmd help:"Report the recovery sets, combination counts, and probabilities of recovery and compromise for a sharing policy"`
+	Explain PolicyExplainCmd `cmd help:"Explain in plain language how a sharing policy, or the policy of a set of shares, recovers the secret, with instructions for each custodian"`
+}
+
+type PolicyAnalyzeCmd struct {
+	GroupThreshold   int      `flag short:"t" help:"Group threshold (the number of groups required to combine)" default:"1"`
+	Groups           []string `flag short:"g" help:"Group definitions, as \"MofN\" strings e.g. 1of1, 2of4, 3of5, etc. (repeatable)" xor:"policy" required`
+	Policy           string   `flag help:"Sharing policy with optionally named groups, instead of --groups and --group-threshold e.g. \"2 of [family:2of3, lawyer:1of1, vault:3of5]\"" xor:"policy" required`
+	PolicyFile       string   `flag name:"policy-file" type:"existingfile" help:"YAML or JSON policy file (see bs --policy-file), with optional per-member loss and compromise probabilities" xor:"policy" required`
+	Loss             float64  `flag short:"l" default:"0.05" help:"Probability that any one share is lost or destroyed"`
+	Compromise       float64  `flag short:"c" default:"0.01" help:"Probability that any one share is compromised (seen by an attacker)"`
+	MemberLoss       []string `flag name:"member-loss" sep:"none" placeholder:"G.M=PROB" help:"Loss probability for member M of group G e.g. 2.1=0.2 (repeatable)"`
+	MemberCompromise []string `flag name:"member-compromise" sep:"none" placeholder:"G.M=PROB" help:"Compromise probability for member M of group G e.g. 2.1=0.2 (repeatable)"`
+}
+
+type PolicyExplainCmd struct {
+	GroupThreshold int      `flag short:"t" help:"Group threshold (the number of groups required to combine)" default:"1"`
+	Groups         []string `flag short:"g" help:"Group definitions, as \"MofN\" strings e.g. 1of1, 2of4, 3of5, etc. (repeatable)"`
+	Policy         string   `flag help:"Sharing policy with optionally named groups, instead of --groups and --group-threshold e.g. \"2 of [family:2of3, lawyer:1of1, vault:3of5]\""`
+	PolicyFile     string   `flag name:"policy-file" type:"existingfile" help:"YAML or JSON policy file (see bs --policy-file), for instructions addressed to each custodian by name"`
+	Files          []string `flag short:"f" name:"file" sep:"none" type:"existingfile" help:"File containing one or more SLIP39 share mnemonics, e.g. one per custodian (repeatable, and combined with any share arguments)"`
+
+	Shares []string `arg help:"SLIP39 share mnemonics to explain instead of a policy, using only their (non-secret) metadata (repeated quoted args, or one per line on stdin)" optional`
+}
+
+type VerifyFingerprintCmd struct {
+	Files []string `flag short:"f" name:"file" sep:"none" type:"existingfile" help:"file containing the SLIP39 share mnemonic"`
+
+	Fingerprint string   `arg help:"share fingerprint e.g. \"28398 G1M2 abandon ability able about\""`
+	Share       []string `arg help:"SLIP39 share mnemonic (quoted arg, or on stdin)" optional`
+}
+
+type CeremonyCmd struct {
+}
+
+type DoctorCmd struct {
+}
+
 type VersionCmd struct {
 }
 
@@ -148,6 +252,7 @@ func (cmd BipRandomCmd) Run(ctx *Context) error {
 	if err != nil {
 		return err
 	}
+	defer wipeBytes(entropy)
 
 	mnemonic, err := bip39.NewMnemonic(entropy)
 	if err != nil {
@@ -169,8 +274,8 @@ func (cmd BipCheckwordCmd) Run(ctx *Context) error {
 	if len(partialWords) == 0 {
 		return errors.New("no mnemonic seed provided")
 	}
-	if len(partialWords) != 11 && len(partialWords) != 23 {
-		return fmt.Errorf("invalid mnemonic seed length %d (must be 11 or 23)",
+	if len(partialWords) < 11 || len(partialWords) > 23 || len(partialWords)%3 != 2 {
+		return fmt.Errorf("invalid mnemonic seed length %d (must be 11, 14, 17, 20, or 23)",
 			len(partialWords))
 	}
 
@@ -179,42 +284,57 @@ func (cmd BipCheckwordCmd) Run(ctx *Context) error {
 		return err
 	}
 
+	// Build candidate mnemonics as word slices rather than joined strings,
+	// so that we don't leave unwipeable copies of each in memory
+	candidate := make([]string, len(partialWords)+1)
+	copy(candidate, partialWords)
+
 	if cmd.Multi {
 		// Validate all the checksumWords
 		for _, w := range checksumWords {
-			seed := strings.Join(append(partialWords, w), " ")
-			ok := bip39.IsMnemonicValid(seed)
-			if !ok {
-				return fmt.Errorf("generated invalid mnemonic: %q", seed)
+			candidate[len(partialWords)] = w
+			if !bip39ChecksumValid(candidate) {
+				return fmt.Errorf("generated invalid mnemonic with checksum word %q", w)
 			}
 		}
 
 		// Output
 		for _, w := range checksumWords {
-			seed := strings.Join(append(partialWords, w), " ")
+			candidate[len(partialWords)] = w
 			if cmd.Word {
 				fmt.Fprintln(ctx.writer, w)
-			} else {
-				fmt.Fprintln(ctx.writer, seed)
+			} else if err := writeSecretWords(ctx.writer, candidate); err != nil {
+				return err
 			}
 		}
 		return nil
 	}
 
 	// Select, validate, and output using a random checksum word
-	i := 0
-	if !cmd.Deterministic {
-		i = rand.Intn(len(checksumWords))
+	i, err := selectChecksumWord(len(checksumWords), cmd.Choice, cmd.Deterministic)
+	if err != nil {
+		return err
 	}
-	seed := strings.Join(append(partialWords, checksumWords[i]), " ")
-	ok := bip39.IsMnemonicValid(seed)
-	if !ok {
-		return fmt.Errorf("generated invalid mnemonic: %q", seed)
+	bits := math.Log2(float64(len(checksumWords)))
+	switch {
+	case cmd.Deterministic:
+		fmt.Fprintf(ctx.stderr(), "Checksum word chosen deterministically (0 bits of entropy)\n")
+	case cmd.Choice > 0:
+		fmt.Fprintf(ctx.stderr(), "Checksum word chosen from %d valid words by --choice (%.0f bits of entropy, if the choice was uniformly random)\n",
+			len(checksumWords), bits)
+	default:
+		fmt.Fprintf(ctx.stderr(), "Checksum word chosen randomly from %d valid words (%.0f bits of entropy)\n",
+			len(checksumWords), bits)
+	}
+	candidate[len(partialWords)] = checksumWords[i]
+	if !bip39ChecksumValid(candidate) {
+		return fmt.Errorf("generated invalid mnemonic with checksum word %q",
+			checksumWords[i])
 	}
 	if cmd.Word {
 		fmt.Fprintln(ctx.writer, checksumWords[i])
-	} else {
-		fmt.Fprintln(ctx.writer, seed)
+	} else if err := writeSecretWords(ctx.writer, candidate); err != nil {
+		return err
 	}
 
 	return nil
@@ -223,6 +343,9 @@ func (cmd BipCheckwordCmd) Run(ctx *Context) error {
 func (cmd BipValCmd) Run(ctx *Context) error {
 	mnemonic, err := readSeedMnemonic(ctx, cmd.Seed)
 	if err != nil {
+		if cmd.Quiet {
+			return errors.New("")
+		}
 		return err
 	}
 
@@ -248,12 +371,13 @@ func (cmd BipSlipCmd) Run(ctx *Context) error {
 		return err
 	}
 
-	entropy, err := bip39.EntropyFromMnemonic(mnemonic)
+	entropy, err := bip39EntropyFromWords(strings.Fields(mnemonic))
 	if err != nil {
 		return err
 	}
+	defer wipeBytes(entropy)
 
-	groups, err := parseGroups(cmd.Groups)
+	policy, err := cmd.sharePolicy()
 	if err != nil {
 		return err
 	}
@@ -262,18 +386,140 @@ func (cmd BipSlipCmd) Run(ctx *Context) error {
 	if cmd.Passphrase != "" {
 		passphrase = []byte(cmd.Passphrase)
 	}
-	shareGroups, err := slip39.GenerateMnemonicsWithPassphrase(
-		cmd.GroupThreshold, groups, entropy, passphrase,
-	)
+	defer wipeBytes(passphrase)
+	var shareGroups slip39.ShareGroups
+	generate := func() error {
+		var err error
+		shareGroups, err = slip39.GenerateMnemonicsWithPassphrase(
+			policy.groupThreshold, policy.memberGroups(), entropy, passphrase,
+		)
+		return err
+	}
+	if cmd.DetSeed != "" {
+		seed, err := parseDeterministicSeed(cmd.DetSeed)
+		if err != nil {
+			return err
+		}
+		defer wipeBytes(seed)
+		fmt.Fprintf(ctx.stderr(), "%s --deterministic-seed: shares are NOT randomly generated - anyone with the seed and the BIP39 mnemonic can recreate every share. Only use for audited ceremonies, and never reuse the seed. Shares are only reproducible with the same versions (%s).\n",
+			color.YellowString("Warning:"), deterministicVersions())
+		err = withDeterministicRand(seed, generate)
+	} else {
+		err = generate()
+	}
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprint(ctx.writer, shareGroups.String())
+	if err := cmd.output(ctx, policy, shareGroups); err != nil {
+		return err
+	}
+
+	displays := newShareDisplays(policy.wallet, policy, shareGroups)
+	mnemonics := make([]string, len(displays))
+	titles := make([]string, len(displays))
+	for i, sd := range displays {
+		mnemonics[i] = sd.mnemonic
+		titles[i] = sd.title()
+	}
+	return writeShareFingerprints(ctx.stderr(), mnemonics, titles)
+}
+
+// output outputs shareGroups, generated using policy, as requested by cmd
+// (to stdout, one at a time, or to files)
+func (cmd BipSlipCmd) output(ctx *Context, policy *sharePolicy, shareGroups slip39.ShareGroups) error {
+	if cmd.Retype && !cmd.OneAtATime {
+		return errors.New("--retype requires --one-at-a-time")
+	}
+	if len(cmd.EncryptTo) > 0 {
+		if cmd.PolicyFile != "" {
+			return errors.New("--encrypt-to cannot be used with --policy-file (use encrypt_to in the policy file)")
+		}
+		return cmd.writeEncrypted(ctx, policy, shareGroups)
+	}
+	displays := newShareDisplays(policy.wallet, policy, shareGroups)
+	if cmd.PolicyFile != "" {
+		var err error
+		displays, err = writeShareDestinations(ctx, cmd.OutputDir, policy, displays)
+		if err != nil || len(displays) == 0 {
+			return err
+		}
+	}
+	if !cmd.OneAtATime {
+		if policy.named() {
+			writeAnnotatedShares(ctx.writer, displays)
+			return nil
+		}
+		fmt.Fprint(ctx.writer, shareGroups.String())
+		return nil
+	}
+
+	// If the seed was read from stdin, prompt via the controlling terminal
+	t := newTerminal(ctx)
+	if len(cmd.Seed) == 0 {
+		tty, err := openTTY(ctx)
+		if err != nil {
+			return err
+		}
+		defer tty.close()
+		t = tty
+	}
+	for i, sd := range displays {
+		err := t.waitForEnter(fmt.Sprintf(
+			"Ready to display share %d of %d (%s) - make sure only its custodian can see the screen, then press Enter...",
+			i+1, len(displays), sd.title()))
+		if err != nil {
+			return err
+		}
+		if err := t.displayShare(sd, i+1, len(displays)); err != nil {
+			return err
+		}
+		if cmd.Retype {
+			if err := t.retypeShare(sd, i+1, len(displays)); err != nil {
+				return err
+			}
+			t.clear()
+		}
+	}
+	fmt.Fprintf(ctx.writer, "%s All %d shares displayed\n",
+		color.GreenString(tickGlyph), len(displays))
 
 	return nil
 }
 
+// sharePolicy returns the sharing policy from cmd.Policy or cmd.PolicyFile,
+// or from cmd.GroupThreshold and cmd.Groups
+func (cmd BipSlipCmd) sharePolicy() (*sharePolicy, error) {
+	return resolveSharePolicy(cmd.GroupThreshold, cmd.Groups, cmd.Policy, cmd.PolicyFile)
+}
+
+// writeEncrypted encrypts each share in shareGroups to its recipient from
+// cmd.EncryptTo, writing one file per share to cmd.OutputDir
+func (cmd BipSlipCmd) writeEncrypted(
+	ctx *Context,
+	policy *sharePolicy,
+	shareGroups slip39.ShareGroups,
+) error {
+	recipients := make([]shareRecipient, len(cmd.EncryptTo))
+	for i, spec := range cmd.EncryptTo {
+		var err error
+		recipients[i], err = parseShareRecipient(spec)
+		if err != nil {
+			return err
+		}
+	}
+	displays := newShareDisplays("", policy, shareGroups)
+	filenames, err := writeEncryptedShares(cmd.OutputDir, recipients, displays)
+	if err != nil {
+		return err
+	}
+	for i, sd := range displays {
+		fmt.Fprintf(ctx.writer, "%s Share %d (%s) encrypted to %s: %s\n",
+			color.GreenString(tickGlyph), i+1, sd.title(), recipients[i].spec, filenames[i])
+	}
+	return nil
+}
+
 func (cmd BipLabelCmd) Run(ctx *Context) error {
 	mnemonic, err := readSeedMnemonic(ctx, cmd.Seed)
 	if err != nil {
@@ -286,44 +532,94 @@ func (cmd BipLabelCmd) Run(ctx *Context) error {
 			len(words))
 	}
 
+	lt, err := resolveLabelScheme(labelSchemeOrDefault(cmd.Labels), true, 1)
+	if err != nil {
+		return err
+	}
+
 	for i := range len(words) {
 		word := words[i]
 		if cmd.Upper {
 			word = strings.ToUpper(word)
 		}
-		fmt.Fprintf(ctx.writer, "%02d %s\n", i+1, word)
+		label := lt.format(labelPosition{group: 1, member: 1, word: i + 1})
+		fmt.Fprintf(ctx.writer, "%s %s\n", label, word)
 	}
 
 	return nil
 }
 
 func (cmd SlipValCmd) Run(ctx *Context) error {
-	mnemonics, err := readShareMnemonics(ctx, cmd.Shares)
+	mnemonics, err := readShareMnemonics(ctx, cmd.Shares, cmd.Files)
 	if err != nil {
 		return err
 	}
 
-	shareGroups, err := slip39.CollateShareGroups(mnemonics)
-	if err != nil {
-		return fmt.Errorf("collating share groups: %w", err)
-	}
-
 	passphrase := []byte{}
 	if cmd.Passphrase != "" {
 		passphrase = []byte(cmd.Passphrase)
 	}
+	defer wipeBytes(passphrase)
+
+	var positions []int
+	if cmd.Diagnose {
+		mnemonics, positions = removeDuplicateShares(ctx.stderr(), mnemonics)
+	} else {
+		mnemonics, positions, err = dedupeShares(ctx.stderr(), mnemonics)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Partition {
+		sets, err := partitionShareSets(mnemonics, positions)
+		if err != nil {
+			return err
+		}
+		return runShareSets(ctx, sets, func(set *shareSet) error {
+			return cmd.validate(ctx, set.mnemonics, set.positions, passphrase)
+		})
+	}
+	return cmd.validate(ctx, mnemonics, positions, passphrase)
+}
+
+// validate validates the set of share mnemonics (whose input positions are in
+// positions), and outputs the secret they all produce
+func (cmd SlipValCmd) validate(ctx *Context, mnemonics []string, positions []int, passphrase []byte) error {
+	if cmd.Diagnose {
+		diagnosis, err := diagnoseShares(mnemonics, positions, passphrase, 0)
+		if err != nil {
+			return err
+		}
+		defer diagnosis.wipe()
+		diagnosis.write(ctx.writer)
+		if err := diagnosis.result(); err != nil {
+			return err
+		}
+	}
+
+	shareGroups, err := slip39.CollateShareGroups(mnemonics)
+	if err != nil {
+		return fmt.Errorf("collating share groups: %w", err)
+	}
 	entropy, combinations, err := shareGroups.ValidateMnemonicsWithPassphrase(
 		passphrase)
 	if err != nil {
 		return fmt.Errorf("validating mnemonics: %w", err)
 	}
+	defer wipeBytes(entropy)
 	plural := ""
 	if combinations > 1 {
 		plural = "s"
 	}
 
-	mnemonic, err := bip39.NewMnemonic(entropy)
-	if err != nil {
+	// If the master secret has no BIP39 equivalent, report it in hex instead
+	mnemonic, err := bip39MnemonicFromSecret(entropy)
+	secretType := "BIP-39 mnemonic"
+	if errors.Is(err, errNoBip39Equivalent) {
+		mnemonic = hex.EncodeToString(entropy)
+		secretType = "master secret"
+	} else if err != nil {
 		return err
 	}
 
@@ -342,8 +638,8 @@ func (cmd SlipValCmd) Run(ctx *Context) error {
 		*/
 
 		if mnemonic != expectedMnemonic {
-			return fmt.Errorf("all SLIP-39 combinations agreed, but on an unexpected mnemonic (passphrase?):\ngot: %s\ncf:  %s",
-				mnemonic, expectedMnemonic)
+			return fmt.Errorf("all SLIP-39 combinations agreed, but on an unexpected %s (passphrase?):\ngot: %s\ncf:  %s",
+				secretType, mnemonic, expectedMnemonic)
 		}
 
 		fmt.Fprintf(ctx.writer,
@@ -355,15 +651,29 @@ func (cmd SlipValCmd) Run(ctx *Context) error {
 	}
 
 	fmt.Fprintf(ctx.writer,
-		"%s All SLIP-39 shares are %s - %d combination%s produced the same BIP-39 mnemonic:\n%s\n",
+		"%s All SLIP-39 shares are %s - %d combination%s produced the same %s:\n%s\n",
 		color.GreenString(tickGlyph), color.GreenString("good"),
-		combinations, plural, mnemonic)
+		combinations, plural, secretType, mnemonic)
 
 	return nil
 }
 
 func (cmd SlipBipCmd) Run(ctx *Context) error {
-	mnemonics, err := readShareMnemonics(ctx, cmd.Shares)
+	if len(cmd.Identities) > 0 {
+		keyPassphrase := []byte(cmd.KeyPass)
+		decrypter, err := newShareDecrypter(cmd.Identities, keyPassphrase)
+		wipeBytes(keyPassphrase)
+		if err != nil {
+			return err
+		}
+		ctx.decrypter = decrypter
+	}
+
+	mnemonics, err := readShareMnemonics(ctx, cmd.Shares, cmd.Files)
+	if err != nil {
+		return err
+	}
+	mnemonics, positions, err := dedupeShares(ctx.stderr(), mnemonics)
 	if err != nil {
 		return err
 	}
@@ -372,14 +682,44 @@ func (cmd SlipBipCmd) Run(ctx *Context) error {
 	if cmd.Passphrase != "" {
 		passphrase = []byte(cmd.Passphrase)
 	}
-	entropy, err := slip39.CombineMnemonicsWithPassphrase(mnemonics, passphrase)
+	defer wipeBytes(passphrase)
+
+	if cmd.Partition {
+		sets, err := partitionShareSets(mnemonics, positions)
+		if err != nil {
+			return err
+		}
+		return runShareSets(ctx, sets, func(set *shareSet) error {
+			return cmd.recover(ctx, set.mnemonics, set.positions, passphrase)
+		})
+	}
+	return cmd.recover(ctx, mnemonics, positions, passphrase)
+}
+
+// recover combines the share mnemonics (whose input positions are in
+// positions), and outputs the secret they produce. Surplus shares are used to
+// cross-check the secret.
+func (cmd SlipBipCmd) recover(ctx *Context, mnemonics []string, positions []int, passphrase []byte) error {
+	entropy, err := combineQuorum(ctx.stderr(), mnemonics, positions, passphrase)
 	if err != nil {
 		return err
 	}
+	defer wipeBytes(entropy)
 	//slog.Info("", "entropy", entropy, "len", len(entropy))
 
-	mnemonic, err := bip39.NewMnemonic(entropy)
-	if err != nil {
+	if cmd.Native {
+		return writeNativeBip32(ctx, entropy, cmd.Passphrase)
+	}
+	if cmd.Hex {
+		fmt.Fprintln(ctx.writer, hex.EncodeToString(entropy))
+		return nil
+	}
+
+	mnemonic, err := bip39MnemonicFromSecret(entropy)
+	if errors.Is(err, errNoBip39Equivalent) {
+		return fmt.Errorf("%w - use --hex to output the raw master secret, or --native for its BIP32 keys",
+			err)
+	} else if err != nil {
 		return err
 	}
 	fmt.Fprintln(ctx.writer, mnemonic)
@@ -387,18 +727,82 @@ func (cmd SlipBipCmd) Run(ctx *Context) error {
 	return nil
 }
 
+// writeNativeBip32 outputs the master fingerprint and account xpubs derived
+// directly from the SLIP39 master secret, as SLIP39 wallets do, and warns
+// that the BIP39 interpretation of the same secret is a different wallet
+func writeNativeBip32(ctx *Context, masterSecret []byte, passphrase string) error {
+	report, err := newBip32Report(masterSecret)
+	if err != nil {
+		return fmt.Errorf("deriving SLIP-39 BIP32 keys: %w", err)
+	}
+
+	fmt.Fprintf(ctx.writer, "SLIP-39 master fingerprint: %s\n", report.fingerprint)
+	for _, xpub := range report.xpubs {
+		fmt.Fprintln(ctx.writer, xpub)
+	}
+
+	// The BIP39 interpretation hashes the mnemonic (and passphrase) into a
+	// 64-byte seed, so its wallet is never the same as the native one
+	mnemonic, err := bip39MnemonicFromSecret(masterSecret)
+	if errors.Is(err, errNoBip39Equivalent) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	seed := bip39.NewSeed(mnemonic, passphrase)
+	defer wipeBytes(seed)
+	bipReport, err := newBip32Report(seed)
+	if err != nil {
+		return fmt.Errorf("deriving BIP-39 BIP32 keys: %w", err)
+	}
+	fmt.Fprintf(ctx.stderr(),
+		"%s the BIP-39 mnemonic for this secret (seedkit sb) controls a different wallet, with master fingerprint %s\n",
+		color.YellowString("Warning:"), bipReport.fingerprint)
+
+	return nil
+}
+
 func (cmd SlipLabelCmd) Run(ctx *Context) error {
-	mnemonics, err := readShareMnemonics(ctx, cmd.Shares)
+	mnemonics, err := readShareMnemonics(ctx, cmd.Shares, cmd.Files)
 	if err != nil {
 		return err
 	}
+	mnemonics, positions, err := dedupeShares(ctx.stderr(), mnemonics)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Partition {
+		sets, err := partitionShareSets(mnemonics, positions)
+		if err != nil {
+			return err
+		}
+		return runShareSets(ctx, sets, func(set *shareSet) error {
+			return cmd.label(ctx, set.mnemonics)
+		})
+	}
+	return cmd.label(ctx, mnemonics)
+}
 
+// label outputs the share mnemonics as labelled words
+func (cmd SlipLabelCmd) label(ctx *Context, mnemonics []string) error {
 	shareGroups, err := slip39.CollateShareGroups(mnemonics)
 	if err != nil {
 		return fmt.Errorf("collating share groups: %w", err)
 	}
 
-	words, err := shareGroups.StringLabelled()
+	lt, err := resolveLabelScheme(labelSchemeOrDefault(cmd.Labels), false,
+		len(shareGroups))
+	if err != nil {
+		return err
+	}
+
+	var words string
+	if lt == nil {
+		words, err = shareGroups.StringLabelled()
+	} else {
+		words, err = formatLabelledShares(shareGroups, lt)
+	}
 	if err != nil {
 		return fmt.Errorf("formatting labelled words: %w", err)
 	}
@@ -407,34 +811,168 @@ func (cmd SlipLabelCmd) Run(ctx *Context) error {
 		words = strings.ToUpper(words)
 	}
 
+	if cmd.Policy != "" || cmd.PolicyFile != "" {
+		policy, err := resolveSharePolicy(1, nil, cmd.Policy, cmd.PolicyFile)
+		if err != nil {
+			return err
+		}
+		return writeHandoffSheets(ctx.writer, policy, shareGroups, words)
+	}
+
 	fmt.Fprint(ctx.writer, words)
 
-	return nil
+	return writeShareFingerprints(ctx.stderr(), mnemonics, nil)
 }
 
 func (cmd LabelSlipCmd) Run(ctx *Context) error {
-	reader := ctx.reader
-	if reader == nil {
-		reader = os.Stdin
+	data, err := readStdin(ctx)
+	if err != nil {
+		return err
+	}
+
+	lines, err := tokenizeInput(data)
+	if err != nil {
+		return err
+	}
+	tokens := flattenTokens(lines)
+	lt, err := resolveInputLabelScheme(labelSchemeOrDefault(cmd.Labels), tokens)
+	if err != nil {
+		return err
+	}
+
+	labelled, err := collateLabelledWords(tokens, lt)
+	if err != nil {
+		return err
+	}
+	if len(labelled) == 0 {
+		return errors.New("no labelled words provided")
+	}
+
+	// Labels may be given in any order, so check each share for missing or
+	// duplicate labels, bad words, and bad checksums. All shares in a set
+	// are the same length, so check against the most common share length
+	// (preferring the longest).
+	lengths := map[int]int{}
+	length := 0
+	groups := false
+	for _, lm := range labelled {
+		lengths[lm.length()]++
+		groups = groups || lm.group > 1
+	}
+	for l, count := range lengths {
+		if count > lengths[length] || (count == lengths[length] && l > length) {
+			length = l
+		}
+	}
+	problems := []string{}
+	if _, err := slip39SecretBits(length); err != nil {
+		problems = append(problems, err.Error())
+	}
+	wordmap := slip39Wordmap()
+	complete := 0
+	for _, lm := range labelled {
+		shareProblems := lm.problems(lt, length)
+		if lm.length() > length {
+			shareProblems = append(shareProblems, fmt.Sprintf(
+				"has %d words, but other shares have %d", lm.length(), length))
+		}
+		for w := 1; w <= length; w++ {
+			t, ok := lm.words[w]
+			if !ok {
+				continue
+			}
+			if _, ok := wordmap[t.word]; !ok {
+				shareProblems = append(shareProblems, fmt.Sprintf(
+					"%s: rejected word %q with label %q (not in the SLIP-39 wordlist)",
+					t.location(), t.word, t.label))
+			}
+		}
+		if len(shareProblems) == 0 {
+			mnemonic, _ := lm.mnemonic(lt)
+			if _, err := slip39.ParseShare(mnemonic); err != nil {
+				shareProblems = append(shareProblems,
+					fmt.Sprintf("invalid share: %s", err.Error()))
+			}
+		}
+		if len(shareProblems) > 0 {
+			for _, problem := range shareProblems {
+				problems = append(problems,
+					fmt.Sprintf("%s: %s", lm.name(groups), problem))
+			}
+			continue
+		}
+
+		// Emit complete shares in label order
+		mnemonic, _ := lm.mnemonic(lt)
+		fmt.Fprintln(ctx.writer, mnemonic)
+		complete++
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d of %d labelled shares complete - problems found:\n  %s",
+			complete, len(labelled), strings.Join(problems, "\n  "))
 	}
-	data, err := io.ReadAll(reader)
+
+	return nil
+}
+
+func (cmd LabelBipCmd) Run(ctx *Context) error {
+	data, err := readStdin(ctx)
 	if err != nil {
-		return fmt.Errorf("reading stdin: %w", err)
+		return err
 	}
 
-	shareGroups, err := slip39.CombineLabelledShares(strings.ToLower(string(data)))
+	lines, err := tokenizeInput(data)
+	if err != nil {
+		return err
+	}
+	lt, err := resolveLabelScheme(labelSchemeOrDefault(cmd.Labels), true, 1)
 	if err != nil {
-		return fmt.Errorf("combining labelled words: %w", err)
+		return err
+	}
+	labelled, err := collateLabelledWords(flattenTokens(lines), lt)
+	if err != nil {
+		return err
+	}
+	if len(labelled) == 0 {
+		return errors.New("no labelled words provided")
+	}
+	if len(labelled) > 1 {
+		return fmt.Errorf("labels refer to %d different mnemonics (expected 1)",
+			len(labelled))
 	}
 
-	shares := shareGroups.String()
-	fmt.Fprint(ctx.writer, shares)
+	if err := checkBip39Words(flattenTokens(lines)); err != nil {
+		return err
+	}
+
+	// Infer the mnemonic length from the highest label, so that missing
+	// trailing words are reported as missing labels
+	words := labelled[0].length()
+	if words > 24 {
+		return fmt.Errorf("labelled words make a %d-word mnemonic (must be 12, 15, 18, 21, or 24 words)",
+			words)
+	}
+	words = max(12, (words+2)/3*3)
+	if problems := labelled[0].problems(lt, words); len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	// Words may be given in any order, so sort and check by label
+	mnemonic, err := labelled[0].mnemonic(lt)
+	if err != nil {
+		return err
+	}
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return errors.New("invalid BIP-39 mnemonic (bad checksum word?)")
+	}
+	fmt.Fprintln(ctx.writer, mnemonic)
 
 	return nil
 }
 
 func (cmd SlipParseCmd) Run(ctx *Context) error {
-	mnemonics, err := readShareMnemonics(ctx, cmd.Shares)
+	mnemonics, err := readShareMnemonics(ctx, cmd.Shares, cmd.Files)
 	if err != nil {
 		return err
 	}
@@ -444,7 +982,14 @@ func (cmd SlipParseCmd) Run(ctx *Context) error {
 		if err != nil {
 			return err
 		}
-		data, err := json.MarshalIndent(s, "", "  ")
+		f, err := fingerprintShare(mnemonic)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(struct {
+			slip39.Share
+			Fingerprint string `json:"fingerprint"`
+		}{s, f.String()}, "", "  ")
 		if err != nil {
 			return err
 		}
@@ -459,10 +1004,11 @@ func (cmd BipEntropyCmd) Run(ctx *Context) error {
 	if err != nil {
 		return err
 	}
-	entropy, err := bip39.EntropyFromMnemonic(mnemonic)
+	entropy, err := bip39EntropyFromWords(strings.Fields(mnemonic))
 	if err != nil {
 		return err
 	}
+	defer wipeBytes(entropy)
 	fmt.Fprintln(ctx.writer, hex.EncodeToString(entropy))
 	return nil
 }
@@ -472,19 +1018,20 @@ func (cmd EntropyBipCmd) Run(ctx *Context) error {
 	if len(cmd.Entropy) > 0 {
 		entropyString = cmd.Entropy
 	} else {
-		entropyBytes, err := io.ReadAll(os.Stdin)
+		input, err := readStdin(ctx)
 		if err != nil {
 			return err
 		}
-		entropyString = strings.TrimSpace(string(entropyBytes))
+		entropyString = strings.TrimSpace(input)
 		//slog.Info("", "entropyString", entropyString, "len", len(entropyString))
 	}
 	entropy, err := hex.DecodeString(entropyString)
 	if err != nil {
 		return err
 	}
+	defer wipeBytes(entropy)
 	//slog.Info("", "entropy", entropy, "len", len(entropy))
-	mnemonic, err := bip39.NewMnemonic(entropy)
+	mnemonic, err := bip39MnemonicFromSecret(entropy)
 	if err != nil {
 		return err
 	}
@@ -493,7 +1040,11 @@ func (cmd EntropyBipCmd) Run(ctx *Context) error {
 }
 
 func (cmd SlipEntropyCmd) Run(ctx *Context) error {
-	mnemonics, err := readShareMnemonics(ctx, cmd.Shares)
+	mnemonics, err := readShareMnemonics(ctx, cmd.Shares, cmd.Files)
+	if err != nil {
+		return err
+	}
+	mnemonics, _, err = dedupeShares(ctx.stderr(), mnemonics)
 	if err != nil {
 		return err
 	}
@@ -502,22 +1053,39 @@ func (cmd SlipEntropyCmd) Run(ctx *Context) error {
 	if err != nil {
 		return err
 	}
+	defer wipeBytes(entropy)
 	fmt.Fprintln(ctx.writer, hex.EncodeToString(entropy))
 	return nil
 }
 
 func (cmd EntropySlipCmd) Run(ctx *Context) error {
-	// TODO
-	_, err := hex.DecodeString(cmd.Entropy)
-	//entropy, err := hex.DecodeString(cmd.Entropy)
+	entropy, err := hex.DecodeString(cmd.Entropy)
 	if err != nil {
 		return err
 	}
+	defer wipeBytes(entropy)
+	bits := len(entropy) * 8
+	if bits < slip39MinSecretBits || bits%16 != 0 {
+		return fmt.Errorf("invalid SLIP39 master secret length %d bits (must be at least %d, and a multiple of 16)",
+			bits, slip39MinSecretBits)
+	}
+
 	groups, err := parseGroups(cmd.Groups)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintf(ctx.writer, "entropy %s, groups %v\n", cmd.Entropy, groups)
+
+	passphrase := []byte(cmd.Passphrase)
+	defer wipeBytes(passphrase)
+	shareGroups, err := slip39.GenerateMnemonicsWithPassphrase(
+		cmd.GroupThreshold, groups, entropy, passphrase,
+	)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(ctx.writer, shareGroups.String())
+
 	return nil
 }
 
@@ -531,114 +1099,324 @@ func standardiseMnemonicBytes(b []byte) string {
 	return reWhitespace.ReplaceAllString(mnemonic, " ")
 }
 
+// parseSeedMnemonic tokenizes input as a BIP39 mnemonic, checking all words
+// are in the BIP39 wordlist, and returns it as a single line with single spaces
+func parseSeedMnemonic(input string) (string, error) {
+	lines, err := tokenizeInput(input)
+	if err != nil {
+		return "", err
+	}
+	tokens := flattenTokens(lines)
+	if err := checkBip39Words(tokens); err != nil {
+		return "", err
+	}
+	return joinTokenWords(tokens), nil
+}
+
 func readSeedMnemonicFromFile(ctx *Context, filename string) (string, error) {
-	data, err := os.ReadFile(filename)
+	data, err := readSecretFileString(filename)
 	if err != nil {
 		return "", fmt.Errorf("reading file %q: %w", filename, err)
 	}
-	// Recombine the input into a single line with single spaces
-	return standardiseMnemonicBytes(data), nil
+	mnemonic, err := parseSeedMnemonic(data)
+	if err != nil {
+		return "", fmt.Errorf("parsing file %q: %w", filename, err)
+	}
+	return mnemonic, nil
 }
 
 func readSeedMnemonicStdin(ctx *Context) (string, error) {
-	reader := ctx.reader
-	if reader == nil {
-		reader = os.Stdin
-	}
-	data, err := io.ReadAll(reader)
+	data, err := readStdin(ctx)
 	if err != nil {
-		return "", fmt.Errorf("reading stdin: %w", err)
+		return "", err
 	}
-	// Recombine the input into a single line with single spaces
-	return standardiseMnemonicBytes(data), nil
+	return parseSeedMnemonic(data)
 }
 
 func readSeedMnemonic(ctx *Context, args []string) (string, error) {
 	var mnemonic string
 	var err error
 	if len(args) > 0 {
-		mnemonic = strings.Join(args, " ")
+		mnemonic, err = parseSeedMnemonic(strings.Join(args, " "))
 	} else {
 		mnemonic, err = readSeedMnemonicStdin(ctx)
-		if err != nil {
-			return "", err
-		}
+	}
+	if err != nil {
+		return "", err
 	}
 	//slog.Info("readSeedMnemonic", "mnemonic", mnemonic)
 	return mnemonic, nil
 }
 
+// readStdin returns the contents of ctx.reader, or os.Stdin if unset
+func readStdin(ctx *Context) (string, error) {
+	reader := ctx.reader
+	if reader == nil {
+		reader = os.Stdin
+	}
+	data, err := readSecretString(reader)
+	if err != nil {
+		return "", fmt.Errorf("reading stdin: %w", err)
+	}
+	return data, nil
+}
+
+// slip39ShareWords returns the number of words in a SLIP39 share for a
+// master secret of the given number of bits
+func slip39ShareWords(bits int) int {
+	return slip39MetadataWords + (bits+9)/10
+}
+
+// slip39SecretBits returns the master secret length in bits for a SLIP39
+// share of the given number of words, or an error if no valid secret length
+// produces a share of that length
+func slip39SecretBits(words int) (int, error) {
+	valueBits := (words - slip39MetadataWords) * 10
+	bits := valueBits - valueBits%16
+	if bits < slip39MinSecretBits || slip39ShareWords(bits) != words {
+		return 0, fmt.Errorf("invalid SLIP39 share length %d words (no valid secret length produces it)",
+			words)
+	}
+	return bits, nil
+}
+
+// parseShareWords returns an error if words don't form a valid (checksummed)
+// SLIP39 share
+func parseShareWords(words []string) error {
+	_, err := slip39.ParseShare(strings.Join(words, " "))
+	return err
+}
+
 // convertWordsToShares converts a slice of mnemonic words to a slice of SLIP39
-// share mnemonics
+// share mnemonics. Where more than one share length is possible (e.g. 60 words
+// could be 3 x 20 or a single 60-word share), the standard 20 and 33 word
+// lengths are tried first, and the first length for which all shares are
+// valid wins.
 func convertWordsToShares(words []string) ([]string, error) {
 	if len(words) == 0 {
 		return nil, errors.New("no SLIP39 mnemonic words provided")
 	}
 
-	mlen := 0
-	if len(words)%33 == 0 {
-		mlen = 33
-	} else if len(words)%20 == 0 {
-		mlen = 20
+	// Candidate share lengths, standard lengths first, then longest first
+	standard := []int{slip39ShareWords(128), slip39ShareWords(256)}
+	candidates := []int{}
+	for _, mlen := range standard {
+		if mlen <= len(words) {
+			candidates = append(candidates, mlen)
+		}
+	}
+	for mlen := len(words); mlen >= slip39ShareWords(slip39MinSecretBits); mlen-- {
+		if _, err := slip39SecretBits(mlen); err == nil && !slices.Contains(standard, mlen) {
+			candidates = append(candidates, mlen)
+		}
 	}
 
-	if mlen == 0 {
-		return nil,
-			fmt.Errorf("invalid SLIP39 word list length - %d is not a multiple of 33 or 20",
-				len(words))
+	split := func(mlen int) []string {
+		mnemonics := make([]string, 0, len(words)/mlen)
+		for i := 0; i < len(words); i += mlen {
+			mnemonics = append(mnemonics, strings.Join(words[i:i+mlen], " "))
+		}
+		return mnemonics
 	}
 
-	mnemonics := make([]string, 0, len(words)/mlen)
-	for i := 0; i < len(words); i += mlen {
-		mnemonics = append(mnemonics, strings.Join(words[i:i+mlen], " "))
+	// Try each consistent split, recording its invalid shares
+	tried := []int{}
+	failures := []string{}
+	for _, c := range candidates {
+		if len(words)%c != 0 {
+			continue
+		}
+		tried = append(tried, c)
+		invalid := []string{}
+		for i := 0; i < len(words); i += c {
+			if err := parseShareWords(words[i : i+c]); err != nil {
+				invalid = append(invalid, fmt.Sprintf("share %d (words %d-%d): %s",
+					i/c+1, i+1, i+c, err.Error()))
+			}
+		}
+		if len(invalid) == 0 {
+			return split(c), nil
+		}
+		failures = append(failures, fmt.Sprintf("  as %d %s of %d words, invalid %s",
+			len(words)/c, plural(len(words)/c, "share"), c, strings.Join(invalid, "; ")))
+	}
+
+	// No consistent split, so check whether we have a sequence of valid
+	// shares of different lengths
+	lengths := []int{}
+	for i := 0; i < len(words); {
+		found := 0
+		for _, c := range candidates {
+			if i+c <= len(words) && parseShareWords(words[i:i+c]) == nil {
+				found = c
+				break
+			}
+		}
+		if found == 0 {
+			lengths = nil
+			break
+		}
+		lengths = append(lengths, found)
+		i += found
+	}
+	if len(lengths) > 1 {
+		if err := checkShareLengths(lengths); err != nil {
+			return nil, err
+		}
 	}
 
-	return mnemonics, nil
+	switch len(tried) {
+	case 0:
+		return nil,
+			fmt.Errorf("invalid SLIP39 word list length - %d is not a multiple of any valid share length (%d, %d, %d, ... %d, etc.)",
+				len(words), slip39ShareWords(128), slip39ShareWords(144),
+				slip39ShareWords(160), slip39ShareWords(256))
+	case 1:
+		// Only one plausible length, so let the caller report the invalid
+		// share(s)
+		return split(tried[0]), nil
+	}
+	return nil, fmt.Errorf("no valid split of %d words into SLIP39 shares:\n%s",
+		len(words), strings.Join(failures, "\n"))
 }
 
-func readShareMnemonics(ctx *Context, args []string) ([]string, error) {
-	var mnemonics []string
-	var err error
+// checkShareLengths checks that all the share lengths (in words) in lengths
+// are the same, and returns an error describing the mismatch if not
+func checkShareLengths(lengths []int) error {
+	for i, l := range lengths {
+		if l == lengths[0] {
+			continue
+		}
+		describe := func(words int) string {
+			bits, err := slip39SecretBits(words)
+			if err != nil {
+				return fmt.Sprintf("%d words (invalid)", words)
+			}
+			return fmt.Sprintf("%d words (%d-bit secret)", words, bits)
+		}
+		return fmt.Errorf("mixed SLIP39 share lengths - share 1 has %s, but share %d has %s (shares from different backups?)",
+			describe(lengths[0]), i+1, describe(l))
+	}
+	return nil
+}
 
-	// If we have args, but fewer than 20, assume they're quoted mnemonics
+// readShareMnemonics returns the SLIP39 share mnemonics from args and files
+// (args first, then each file in order), or if neither is set, from
+// ctx.reader/stdin
+func readShareMnemonics(ctx *Context, args, files []string) ([]string, error) {
+	mnemonics := []string{}
 	if len(args) > 0 && len(args) < 20 {
-		mnemonics = make([]string, 0, len(args))
-		for _, m := range args {
-			mnemonics = append(mnemonics, strings.ToLower(m))
+		// If we have args, but fewer than 20, assume they're quoted mnemonics,
+		// and treat each as a separate block
+		for i, arg := range args {
+			m, err := parseShareMnemonics(arg)
+			if err != nil {
+				return nil, fmt.Errorf("parsing argument %d: %w", i+1, err)
+			}
+			mnemonics = append(mnemonics, m...)
 		}
 	} else if len(args) >= 20 {
 		// Otherwise assume we have a single mnemonic with spaces
-		mnemonics = []string{strings.ToLower(strings.Join(args, " "))}
+		m, err := parseShareMnemonics(strings.Join(args, " "))
+		if err != nil {
+			return nil, err
+		}
+		mnemonics = append(mnemonics, m...)
 	}
 
-	// If we have no args, read mnemonics from ctx.reader/stdin, one share
-	// per line, or possibly one word per line
-	if len(mnemonics) == 0 {
-		reader := ctx.reader
-		if reader == nil {
-			reader = os.Stdin
+	for _, filename := range files {
+		var data string
+		var err error
+		if ctx.decrypter != nil {
+			data, err = ctx.decrypter.readFile(filename)
+		} else {
+			data, err = readSecretFileString(filename)
 		}
-		scanner := bufio.NewScanner(reader)
-		for scanner.Scan() {
-			m := strings.TrimSpace(scanner.Text())
-			mnemonics = append(mnemonics, strings.ToLower(m))
+		if err != nil {
+			return nil, fmt.Errorf("reading file %q: %w", filename, err)
 		}
-		if err := scanner.Err(); err != nil {
-			return nil, fmt.Errorf("scanning input: %w", err)
+		m, err := parseShareMnemonics(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing file %q: %w", filename, err)
 		}
+		mnemonics = append(mnemonics, m...)
 	}
-	// If the first mnemonic contains no spaces, assume we have one word per line
-	if !strings.Contains(strings.TrimSpace(mnemonics[0]), " ") {
-		mnemonics, err = convertWordsToShares(mnemonics)
+
+	if len(args) == 0 && len(files) == 0 {
+		// If we have no args or files, read mnemonics from ctx.reader/stdin
+		input, err := readStdin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		mnemonics, err = parseShareMnemonics(input)
 		if err != nil {
 			return nil, err
 		}
 	}
+
+	// Check all shares are the same length
+	lengths := make([]int, len(mnemonics))
+	for i, m := range mnemonics {
+		lengths[i] = len(strings.Fields(m))
+	}
+	if err := checkShareLengths(lengths); err != nil {
+		return nil, err
+	}
 	//slog.Info("readShareMnemonics", "mnemonics", mnemonics)
 
 	return mnemonics, nil
 }
 
+// parseShareMnemonics tokenizes input into SLIP39 share mnemonics. Input may
+// have one share per line, or one (or a few) words per line, optionally in
+// blocks separated by blank lines (e.g. one block per share).
+func parseShareMnemonics(input string) ([]string, error) {
+	lines, err := tokenizeInput(input)
+	if err != nil {
+		return nil, err
+	}
+	tokens := flattenTokens(lines)
+	if len(tokens) == 0 {
+		return nil, errors.New("no SLIP39 mnemonic words provided")
+	}
+	if err := checkSlip39Words(tokens); err != nil {
+		return nil, err
+	}
+
+	// If any line is long enough to be a share, assume one share per line
+	for _, tl := range lines {
+		if len(tl.tokens) >= slip39ShareWords(slip39MinSecretBits) {
+			mnemonics := make([]string, len(lines))
+			for i, tl := range lines {
+				mnemonics[i] = joinTokenWords(tl.tokens)
+			}
+			return mnemonics, nil
+		}
+	}
+
+	// Otherwise we have one (or a few) words per line, so split each block
+	// into shares separately
+	mnemonics := []string{}
+	for _, block := range splitBlocks(lines) {
+		blockTokens := flattenTokens(block)
+		words := make([]string, len(blockTokens))
+		for i, t := range blockTokens {
+			words[i] = t.word
+		}
+		m, err := convertWordsToShares(words)
+		if err != nil {
+			if len(lines) > len(block) {
+				return nil, fmt.Errorf("block starting on line %d: %w",
+					block[0].line, err)
+			}
+			return nil, err
+		}
+		mnemonics = append(mnemonics, m...)
+	}
+
+	return mnemonics, nil
+}
+
 func bip39Entropy(partialWords []string) (*big.Int, error) {
 	i := big.NewInt(0)
 	for _, w := range partialWords {
@@ -652,6 +1430,26 @@ func bip39Entropy(partialWords []string) (*big.Int, error) {
 	return i, nil
 }
 
+// selectChecksumWord returns the index of the checksum word to use from n
+// valid checksum words: choice-1 if choice is set, 0 if deterministic is
+// true, and otherwise chosen uniformly at random using crypto/rand
+func selectChecksumWord(n, choice int, deterministic bool) (int, error) {
+	switch {
+	case choice != 0:
+		if choice < 1 || choice > n {
+			return 0, fmt.Errorf("invalid --choice %d (must be from 1 to %d)", choice, n)
+		}
+		return choice - 1, nil
+	case deterministic:
+		return 0, nil
+	}
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("choosing random checksum word: %w", err)
+	}
+	return int(i.Int64()), nil
+}
+
 // bip39ChecksumWords generates a slice of possible checksum words for the
 // BIP39 partial mnemonic in partialWords
 // Based on https://github.com/avsync/bip39chk
@@ -660,6 +1458,7 @@ func bip39ChecksumWords(partialWords []string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer wipeBigInt(entropy)
 
 	size := len(partialWords) + 1
 	checksumBits := size / 3
@@ -673,12 +1472,12 @@ func bip39ChecksumWords(partialWords []string) ([]string, error) {
 	wordlist := bip39.GetWordList()
 	entropyCandidate := entropyBase
 	buf := make([]byte, entropySize)
+	defer wipeBytes(buf)
 	for i := range iterations {
 		entropyBytes := entropyCandidate.FillBytes(buf)
-		h := sha256.New()
-		h.Write(entropyBytes)
-		hash := h.Sum(nil)
+		hash := sha256.Sum256(entropyBytes)
 		checksum := int(hash[0]) >> (8 - checksumBits)
+		wipeBytes(hash[:])
 		idx := (i << checksumBits) + checksum
 		checkword := wordlist[idx]
 		checksums = append(checksums, checkword)
@@ -688,6 +1487,21 @@ func bip39ChecksumWords(partialWords []string) ([]string, error) {
 	return checksums, nil
 }
 
+// errNoBip39Equivalent is returned when a master secret has a length that
+// cannot be represented as a BIP39 mnemonic
+var errNoBip39Equivalent = errors.New("no BIP-39 equivalent")
+
+// bip39MnemonicFromSecret converts secret to a BIP39 mnemonic, returning an
+// error wrapping errNoBip39Equivalent if secret is not a BIP39 length
+func bip39MnemonicFromSecret(secret []byte) (string, error) {
+	bits := len(secret) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", fmt.Errorf("%d-bit secret has %w (BIP-39 supports 128, 160, 192, 224 or 256 bits)",
+			bits, errNoBip39Equivalent)
+	}
+	return bip39.NewMnemonic(secret)
+}
+
 func parseGroups(groupstr []string) ([]slip39.MemberGroupParameters, error) {
 	groups := make([]slip39.MemberGroupParameters, 0, len(groupstr))
 	for _, g := range groupstr {
@@ -697,10 +1511,8 @@ func parseGroups(groupstr []string) ([]slip39.MemberGroupParameters, error) {
 		}
 		t, _ := strconv.Atoi(matches[1])
 		n, _ := strconv.Atoi(matches[2])
-		if t > GroupLimit || n > GroupLimit || t > n {
-			return nil,
-				fmt.Errorf("invalid group format: %q (not \"MofN\", M <= N, N <= %d)",
-					g, GroupLimit)
+		if err := validateMemberGroup(t, n); err != nil {
+			return nil, fmt.Errorf("invalid group %q: %w", g, err)
 		}
 		group := slip39.MemberGroupParameters{
 			MemberThreshold: t,
@@ -726,10 +1538,17 @@ func runCLI(wtr io.Writer) error {
 			TimeFormat: " ",
 		}),
 	))
+	if cli.Paranoid {
+		err := paranoidCheck(os.Stderr, commandPath(ctx.Command()), newDoctorEnv())
+		if err != nil {
+			return err
+		}
+	}
 	return ctx.Run(&Context{writer: wtr, verbose: cli.Verbose})
 }
 
 func main() {
+	hardenProcess(os.Stderr)
 	err := runCLI(os.Stdout)
 	if err != nil {
 		errstr := err.Error()
@@ -2,13 +2,22 @@ package main
 
 import (
 	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
 
+	"github.com/gavincarr/go-slip39"
 	"github.com/google/go-cmp/cmp"
+	"github.com/tyler-smith/go-bip39"
 )
 
 func TestBip39ChecksumWords(t *testing.T) {
@@ -67,6 +76,13 @@ func TestBipCheckword(t *testing.T) {
 			PartialMnemonic: []string{
 				"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"},
 		}, "about\n", ""},
+		// Test Choice
+		{BipCheckwordCmd{
+			Word:   true,
+			Choice: 128,
+			PartialMnemonic: []string{
+				"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"},
+		}, "wrap\n", ""},
 		// Test Multi: true
 		{BipCheckwordCmd{
 			Multi: true,
@@ -108,13 +124,80 @@ func TestBipCheckword(t *testing.T) {
 			PartialMnemonic: []string{
 				"all hour make first leader extend hole alien behind guard gospel lava path output census museum junior mass reopen famous sing advance salt"},
 		}, "", "bipCheckwordsWords2.txt"},
+
+		// 14 words
+		// Test Word: true, Deterministic: true
+		{BipCheckwordCmd{
+			Word:          true,
+			Deterministic: true,
+			PartialMnemonic: []string{
+				"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"},
+		}, "address\n", ""},
+		// Test Multi: true
+		{BipCheckwordCmd{
+			Multi: true,
+			PartialMnemonic: []string{
+				"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"},
+		}, "", "bipCheckwordsMnemonics3.txt"},
+		// Test Multi: true, Word: true
+		{BipCheckwordCmd{
+			Multi: true,
+			Word:  true,
+			PartialMnemonic: []string{
+				"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"},
+		}, "", "bipCheckwordsWords3.txt"},
+
+		// 17 words
+		// Test Word: true, Deterministic: true
+		{BipCheckwordCmd{
+			Word:          true,
+			Deterministic: true,
+			PartialMnemonic: []string{
+				"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter"},
+		}, "always\n", ""},
+		// Test Multi: true
+		{BipCheckwordCmd{
+			Multi: true,
+			PartialMnemonic: []string{
+				"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter"},
+		}, "", "bipCheckwordsMnemonics4.txt"},
+		// Test Multi: true, Word: true
+		{BipCheckwordCmd{
+			Multi: true,
+			Word:  true,
+			PartialMnemonic: []string{
+				"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter"},
+		}, "", "bipCheckwordsWords4.txt"},
+
+		// 20 words
+		// Test Word: true, Deterministic: true
+		{BipCheckwordCmd{
+			Word:          true,
+			Deterministic: true,
+			PartialMnemonic: []string{
+				"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"},
+		}, "admit\n", ""},
+		// Test Multi: true
+		{BipCheckwordCmd{
+			Multi: true,
+			PartialMnemonic: []string{
+				"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"},
+		}, "", "bipCheckwordsMnemonics5.txt"},
+		// Test Multi: true, Word: true
+		{BipCheckwordCmd{
+			Multi: true,
+			Word:  true,
+			PartialMnemonic: []string{
+				"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"},
+		}, "", "bipCheckwordsWords5.txt"},
 	}
 
 	for _, tc := range tests {
 		var buf bytes.Buffer
 		ctx := Context{
-			writer:  &buf,
-			verbose: 0,
+			writer:    &buf,
+			errWriter: io.Discard,
+			verbose:   0,
 		}
 
 		err := tc.cmd.Run(&ctx)
@@ -377,8 +460,9 @@ func TestSlipVal_Success(t *testing.T) {
 		var buf2 bytes.Buffer
 		cmd := SlipValCmd{}
 		ctx := Context{
-			reader: buf1,
-			writer: &buf2,
+			reader:    buf1,
+			writer:    &buf2,
+			errWriter: io.Discard,
 		}
 
 		err := cmd.Run(&ctx)
@@ -405,6 +489,7 @@ func TestSlipVal_CheckFile(t *testing.T) {
 		{"slip1s.txt", "bip1s.txt"},
 		{"slip1sn.txt", "bip1s.txt"},
 		{"slip1s.txt", "bip1sn.txt"},
+		{"slip1sb.txt", "bip1s.txt"},
 		{"slip1sn.txt", "bip1sn.txt"},
 	}
 
@@ -450,9 +535,6 @@ func TestSlipVal_Failure(t *testing.T) {
 		t.Fatal(err)
 	}
 	for _, tf := range testfiles {
-		if strings.Contains(tf, "todo") {
-			continue
-		}
 		data, err := ioutil.ReadFile(tf)
 		if err != nil {
 			t.Fatal(err)
@@ -589,6 +671,7 @@ func TestSlipParse(t *testing.T) {
 		{"slip1s.txt", "slip1s.json"},
 		{"slip1sn.txt", "slip1s.json"},
 		{"slip1su.txt", "slip1s.json"},
+		{"slip1sb.txt", "slip1s.json"},
 	}
 
 	for _, tc := range tests {
@@ -620,3 +703,2410 @@ func TestSlipParse(t *testing.T) {
 		}
 	}
 }
+
+// Test BIP32 derivation against the BIP-32 and SLIP-39 test vectors
+func TestBip32(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		seed string
+		path string
+		xprv string
+		xpub string
+	}{
+		// BIP-32 test vector 1
+		{"000102030405060708090a0b0c0d0e0f", "m",
+			"xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi",
+			"xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"},
+		{"000102030405060708090a0b0c0d0e0f", "m/0'",
+			"xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7",
+			"xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw"},
+		{"000102030405060708090a0b0c0d0e0f", "m/0'/1",
+			"xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs",
+			"xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ"},
+		// SLIP-39 test vector 1 master secret
+		{"bb54aac4b89dc868ba37d9cc21b2cece", "m",
+			"xprv9s21ZrQH143K4QViKpwKCpS2zVbz8GrZgpEchMDg6KME9HZtjfL7iThE9w5muQA4YPHKN1u5VM1w8D4pvnjxa2BmpGMfXr7hnRrRHZ93awZ",
+			""},
+	}
+
+	for _, tc := range tests {
+		seed, err := hex.DecodeString(tc.seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		master, err := newMasterKey(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := master.derivePath(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := key.String(); got != tc.xprv {
+			t.Errorf("%s %s: want xprv %q, got %q", tc.seed, tc.path, tc.xprv, got)
+		}
+		if tc.xpub == "" {
+			continue
+		}
+		if got := key.xpub(versionXpub); got != tc.xpub {
+			t.Errorf("%s %s: want xpub %q, got %q", tc.seed, tc.path, tc.xpub, got)
+		}
+	}
+}
+
+// Test native SLIP-39 BIP32 output from SlipBip
+func TestSlipBip_Native(t *testing.T) {
+	t.Parallel()
+
+	cmd := SlipBipCmd{
+		Passphrase: "TREZOR",
+		Native:     true,
+		Shares: []string{
+			"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard",
+		},
+	}
+	var buf, errBuf bytes.Buffer
+	ctx := Context{
+		writer:    &buf,
+		errWriter: &errBuf,
+	}
+
+	err := cmd.Run(&ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SLIP-39 test vector 1, whose master key is checked in TestBip32
+	want := `SLIP-39 master fingerprint: 828ecab2
+m/44'/0'/0' xpub6CxseX358AsSuy97fF3iAgy6zwDRqpvGV7Ha3cgEbuQikuMKqXEGVcDrNyAphVmnh39yuXWB1V3jwNjMWCn2CAH6ScQBMpupNruqxcBHM7j
+m/49'/0'/0' ypub6X9mmdSm1j6qUGn7YLREmQw6hqNWiz4NxxgZX4C8yRV51ASzy5QJXpEY2Lw2nbbqhqgighsSqUPgAQEpiWGCsK624fa9CG9MDmz9su4GPQ6
+m/84'/0'/0' zpub6rFiEEAxZAPsWm4WUWCcNZcMq6ALskFEujtTMo6aEP3A3qWazeeogERJpjgwnbzX3FUf34Dpfb5xa92yJSqb7eChwvhxjDvJZoV6m2LFpL9
+m/86'/0'/0' xpub6DAPBETkpJrkriwfkVyxUngww1nFCXtdpqAx9EwTGR25AbbQFYLwAtC5AnjCfMrAVHVLTZDErojAHLiWwuozsnEyGKg2peEiUihtaYCMc3R
+`
+	if buf.String() != want {
+		t.Errorf("sb --native output mismatch - got:\n%sexpected:\n%s", buf.String(), want)
+	}
+	warning := ansiRE.ReplaceAllString(errBuf.String(), "")
+	if !strings.Contains(warning, "controls a different wallet, with master fingerprint 54b5c004") {
+		t.Errorf("missing BIP-39 divergence warning: %q", warning)
+	}
+}
+
+func TestSlip39SecretBits(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		words int
+		bits  int
+	}{
+		{20, 128},
+		{22, 144},
+		{23, 160},
+		{33, 256},
+		{59, 512},
+		// Invalid lengths
+		{19, 0},
+		{21, 0},
+		{24, 0},
+		{34, 0},
+	}
+
+	for _, tc := range tests {
+		bits, err := slip39SecretBits(tc.words)
+		if tc.bits == 0 {
+			if err == nil {
+				t.Errorf("%d words unexpectedly succeeded (%d bits)", tc.words, bits)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d words: %s", tc.words, err.Error())
+			continue
+		}
+		if bits != tc.bits {
+			t.Errorf("%d words: want %d bits, got %d", tc.words, tc.bits, bits)
+		}
+		if words := slip39ShareWords(bits); words != tc.words {
+			t.Errorf("%d bits: want %d words, got %d", bits, tc.words, words)
+		}
+	}
+}
+
+// Test SLIP-39 shares for secrets with no BIP-39 equivalent
+func TestSlipBip_NonBip39Lengths(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		slipfile  string
+		threshold int
+		want      string
+	}{
+		{"slip144s.txt", 3, strings.Repeat("a5", 18)},
+		{"slip512s.txt", 2, strings.Repeat("0f", 64)},
+	}
+
+	for _, tc := range tests {
+		data, err := ioutil.ReadFile("testdata/" + tc.slipfile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares := strings.Split(strings.TrimSpace(string(data)), "\n")
+		shares = shares[:tc.threshold]
+
+		// Without --hex we should get a clear error
+		var buf bytes.Buffer
+		ctx := Context{
+			writer: &buf,
+		}
+		cmd := SlipBipCmd{Shares: shares}
+		err = cmd.Run(&ctx)
+		if !errors.Is(err, errNoBip39Equivalent) {
+			t.Errorf("%s: expected errNoBip39Equivalent, got %v", tc.slipfile, err)
+		}
+
+		// With --hex, and one word per line
+		buf.Reset()
+		ctx.reader = strings.NewReader(
+			strings.Join(strings.Fields(strings.Join(shares, " ")), "\n"))
+		cmd = SlipBipCmd{Hex: true}
+		err = cmd.Run(&ctx)
+		if err != nil {
+			t.Errorf("%s: %s", tc.slipfile, err.Error())
+			continue
+		}
+		if got := strings.TrimSpace(buf.String()); got != tc.want {
+			t.Errorf("%s: want %q, got %q", tc.slipfile, tc.want, got)
+		}
+	}
+}
+
+// Test that shares of different lengths are reported as such
+func TestSlipVal_MixedLengths(t *testing.T) {
+	t.Parallel()
+
+	var input string
+	for _, tf := range []string{"slip144s.txt", "slip512s.txt"} {
+		data, err := ioutil.ReadFile("testdata/" + tf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input += string(data)
+	}
+
+	// One share per line, and one word per line
+	for _, data := range []string{input, strings.Join(strings.Fields(input), "\n")} {
+		var buf bytes.Buffer
+		cmd := SlipValCmd{}
+		ctx := Context{
+			reader: strings.NewReader(data),
+			writer: &buf,
+		}
+		err := cmd.Run(&ctx)
+		if err == nil || !strings.Contains(err.Error(), "mixed SLIP39 share lengths") {
+			t.Errorf("expected mixed share lengths error, got %v", err)
+		}
+	}
+}
+
+// Test that word lists are split into the standard share lengths first, and
+// that a typo is reported against its share for each split tried
+func TestConvertWordsToShares(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		filename string
+		typo     int // index of the word to replace, or -1
+		want     []string
+	}{
+		{"testdata/slip5f.txt", -1, nil},
+		{"testdata/slip1s.txt", -1, nil},
+		{"testdata/slip5f.txt", 24, []string{
+			"no valid split of 60 words into SLIP39 shares:\n",
+			"  as 3 shares of 20 words, invalid share 2 (words 21-40): ",
+			"  as 1 share of 60 words, invalid share 1 (words 1-60): ",
+		}},
+		{"testdata/slip1s.txt", 70, []string{
+			"no valid split of 99 words into SLIP39 shares:\n",
+			"  as 3 shares of 33 words, invalid share 3 (words 67-99): ",
+			"  as 1 share of 99 words, invalid share 1 (words 1-99): ",
+		}},
+	}
+
+	for _, tc := range tests {
+		data := readTestFile(t, tc.filename)
+		words := strings.Fields(data)
+		if tc.typo >= 0 {
+			words[tc.typo] = "academic"
+		}
+		mnemonics, err := convertWordsToShares(words)
+		if tc.want == nil {
+			if err != nil {
+				t.Errorf("%s: %s", tc.filename, err.Error())
+				continue
+			}
+			if diff := cmp.Diff(strings.Split(strings.TrimSpace(data), "\n"), mnemonics); diff != "" {
+				t.Errorf("%s: mismatch (-want +got):\n%s", tc.filename, diff)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s with a typo in word %d unexpectedly succeeded", tc.filename, tc.typo+1)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s with a typo in word %d: expected %q in error, got:\n%s",
+					tc.filename, tc.typo+1, want, err.Error())
+			}
+		}
+	}
+}
+
+func TestTokenizeInput(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		input  string
+		words  string
+		labels string
+		err    string
+	}{
+		{"abandon ability able", "abandon ability able", "", ""},
+		{"1. abandon 2. ability 3. able", "abandon ability able", "1 2 3", ""},
+		{"01 ALL\n02 HOUR\n\n03 MAKE\n", "all hour make", "01 02 03", ""},
+		{"abandon,ability, able;about", "abandon ability able about", "", ""},
+		{"# my seed\nabandon ability // first two\n  able # third\n", "abandon ability able", "", ""},
+		{"1.abandon 2)ability (3) able.", "abandon ability able", "1 2 3", ""},
+		{"101,carpet\n102,morning", "carpet morning", "101 102", ""},
+		{"A01 abandon\na02 ability", "abandon ability", "a01 a02", ""},
+		{"1-01 abandon g1m2-07 ability", "abandon ability", "1-01 g1m2-07", ""},
+		{"1,01,abandon", "abandon", "1-01", ""},
+		// Failures
+		{"abandon ab4ndon able", "", "", `line 1, field 2: rejected token "ab4ndon" (mixes digits into a word)`},
+		{"abandon\nabil_ity", "", "", `line 2, field 1: rejected token "abil_ity" (contains invalid characters)`},
+		{"01 abandon\n02\n", "", "", `line 2, field 1: rejected label "02" (no word follows it)`},
+	}
+
+	for _, tc := range tests {
+		lines, err := tokenizeInput(tc.input)
+		if tc.err != "" {
+			if err == nil {
+				t.Errorf("%q unexpectedly succeeded", tc.input)
+			} else if err.Error() != tc.err {
+				t.Errorf("%q: want error %q, got %q", tc.input, tc.err, err.Error())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", tc.input, err.Error())
+			continue
+		}
+		tokens := flattenTokens(lines)
+		if got := joinTokenWords(tokens); got != tc.words {
+			t.Errorf("%q: want words %q, got %q", tc.input, tc.words, got)
+		}
+		labels := []string{}
+		for _, tok := range tokens {
+			if tok.label != "" {
+				labels = append(labels, tok.label)
+			}
+		}
+		if got := strings.Join(labels, " "); got != tc.labels {
+			t.Errorf("%q: want labels %q, got %q", tc.input, tc.labels, got)
+		}
+	}
+}
+
+// Test that shares given as arguments and in files are combined, in order
+func TestReadShareMnemonics_ArgsAndFiles(t *testing.T) {
+	t.Parallel()
+
+	shares := strings.Split(strings.TrimSpace(readTestFile(t, "testdata/slip1s.txt")), "\n")
+	mnemonics, err := readShareMnemonics(&Context{}, shares[2:], []string{"testdata/slip1s.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]string{shares[2]}, shares...)
+	if diff := cmp.Diff(want, mnemonics); diff != "" {
+		t.Errorf("readShareMnemonics mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// Test that bad words are reported with their location
+func TestBadWordLocations(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseSeedMnemonic("1. all 2. hour 3. mkae")
+	want := `line 1, field 6: rejected word "mkae" (not in the BIP-39 wordlist)`
+	if err == nil || err.Error() != want {
+		t.Errorf("want error %q, got %v", want, err)
+	}
+
+	ctx := Context{reader: strings.NewReader("academic\nacid\nabandon\n")}
+	_, err = readShareMnemonics(&ctx, nil, nil)
+	want = `line 3, field 1: rejected word "abandon" (not in the SLIP-39 wordlist)`
+	if err == nil || err.Error() != want {
+		t.Errorf("want error %q, got %v", want, err)
+	}
+
+	if len(slip39Wordmap()) != 1024 {
+		t.Errorf("bad SLIP-39 wordmap length %d", len(slip39Wordmap()))
+	}
+}
+
+// Test numbered and commented input for BIP-39 and SLIP-39 commands
+func TestTolerantInput(t *testing.T) {
+	t.Parallel()
+
+	// Numbered, uppercased BIP-39 seed, two words per line with a comment
+	data, err := ioutil.ReadFile("testdata/bip1s.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := strings.Fields(string(data))
+	var sb strings.Builder
+	sb.WriteString("# seed backup\n")
+	for i, w := range words {
+		fmt.Fprintf(&sb, "%d. %s ", i+1, strings.ToUpper(w))
+		if i%2 == 1 {
+			sb.WriteString("\n")
+		}
+	}
+	var buf bytes.Buffer
+	cmd := BipValCmd{}
+	ctx := Context{
+		reader: strings.NewReader(sb.String()),
+		writer: &buf,
+	}
+	if err := cmd.Run(&ctx); err != nil {
+		t.Errorf("numbered BIP-39 seed failed: %s", err.Error())
+	}
+
+	// Comma-separated SLIP-39 shares with blank lines and comments
+	data, err = ioutil.ReadFile("testdata/slip1s.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb.Reset()
+	for i, share := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fmt.Fprintf(&sb, "# share %d\n%s\n\n", i+1,
+			strings.Join(strings.Fields(share), ", "))
+	}
+	buf.Reset()
+	cmd2 := SlipValCmd{}
+	ctx = Context{
+		reader: strings.NewReader(sb.String()),
+		writer: &buf,
+	}
+	if err := cmd2.Run(&ctx); err != nil {
+		t.Errorf("comma-separated SLIP-39 shares failed: %s", err.Error())
+	}
+}
+
+// Test reading SLIP-39 shares from multiple files, one share per file
+func TestSlipVal_Files(t *testing.T) {
+	t.Parallel()
+
+	data, err := ioutil.ReadFile("testdata/slip2s.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write each share to a separate file, one word per line
+	dir := t.TempDir()
+	files := []string{}
+	for i, share := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		filename := filepath.Join(dir, fmt.Sprintf("custodian%d.txt", i+1))
+		words := strings.Join(strings.Fields(share), "\n")
+		err := ioutil.WriteFile(filename, []byte(words+"\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, filename)
+	}
+
+	var buf bytes.Buffer
+	cmd := SlipValCmd{Files: files}
+	ctx := Context{
+		writer: &buf,
+	}
+	err = cmd.Run(&ctx)
+	if err != nil {
+		t.Fatalf("SlipVal on files failed: %s", err.Error())
+	}
+	if !strings.Contains(buf.String(), "good") {
+		t.Errorf("unexpected output on successful sv: %s", buf.String())
+	}
+
+	// A bad file should be reported by name
+	bad := filepath.Join(dir, "bad.txt")
+	if err := ioutil.WriteFile(bad, []byte("academic\nacid\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmd = SlipValCmd{Files: append(files, bad)}
+	err = cmd.Run(&ctx)
+	if err == nil || !strings.Contains(err.Error(), bad) {
+		t.Errorf("expected error naming %q, got %v", bad, err)
+	}
+}
+
+func TestSplitBlocks(t *testing.T) {
+	t.Parallel()
+
+	lines, err := tokenizeInput("\n# header\n\nacademic\nacid\n\n\n# share 2\nacne\n\nagain\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := splitBlocks(lines)
+	got := []string{}
+	for _, block := range blocks {
+		got = append(got, joinTokenWords(flattenTokens(block)))
+	}
+	want := []string{"academic acid", "acne", "again"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("block mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLabelTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		template string
+		pos      labelPosition
+		label    string
+	}{
+		{"{w:02}", labelPosition{1, 1, 7}, "07"},
+		{"{m:A}{w:02}", labelPosition{1, 2, 12}, "B12"},
+		{"{g}{m:a}{w:02}", labelPosition{2, 28, 3}, "2ab03"},
+		{"{g}-{m}-{w:02}", labelPosition{3, 1, 20}, "3-1-20"},
+		{"G{g}M{m}-{w:02}", labelPosition{1, 2, 7}, "G1M2-07"},
+		{"({m}.{w})", labelPosition{1, 4, 33}, "(4.33)"},
+	}
+
+	for _, tc := range tests {
+		lt, err := newLabelTemplate(tc.template)
+		if err != nil {
+			t.Fatalf("newLabelTemplate(%q) failed: %s", tc.template, err.Error())
+		}
+		if got := lt.format(tc.pos); got != tc.label {
+			t.Errorf("%q format %v: got %q, want %q", tc.template, tc.pos, got, tc.label)
+		}
+		// tokenizeInput lowercases and trims labels, so parse should too
+		label := strings.Trim(strings.ToLower(tc.label), fieldTrimChars)
+		pos, err := lt.parse(label)
+		if err != nil {
+			t.Errorf("%q parse %q failed: %s", tc.template, label, err.Error())
+			continue
+		}
+		if pos != tc.pos {
+			t.Errorf("%q parse %q: got %v, want %v", tc.template, label, pos, tc.pos)
+		}
+	}
+
+	// Padded fields accept unpadded numbers, unless directly following
+	// another numeric field
+	for _, tc := range []struct {
+		template string
+		label    string
+		pos      labelPosition
+	}{
+		{"{w:02}", "7", labelPosition{1, 1, 7}},
+		{"G{g}M{m}-{w:02}", "g1m2-7", labelPosition{1, 2, 7}},
+		{"{m:A}{w:02}", "b3", labelPosition{1, 2, 3}},
+		{"{m}{w:02}", "1207", labelPosition{1, 12, 7}},
+	} {
+		lt, err := newLabelTemplate(tc.template)
+		if err != nil {
+			t.Fatalf("newLabelTemplate(%q) failed: %s", tc.template, err.Error())
+		}
+		if pos, err := lt.parse(tc.label); err != nil || pos != tc.pos {
+			t.Errorf("%q parse %q: got %v (%v), want %v", tc.template, tc.label, pos, err, tc.pos)
+		}
+	}
+
+	for _, template := range []string{"{m}", "{w}{w}", "{x}{w}", "{w:2}", "{{w}"} {
+		if _, err := newLabelTemplate(template); err == nil {
+			t.Errorf("newLabelTemplate(%q) unexpectedly succeeded", template)
+		}
+	}
+	if _, err := resolveLabelScheme("roman", false, 1); err == nil {
+		t.Errorf("resolveLabelScheme(\"roman\") unexpectedly succeeded")
+	}
+}
+
+// Test labelled word round-trips using each label scheme
+func TestLabelSchemes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		scheme   string
+		sharesTf string
+		labelsTf string
+	}{
+		{"numeric", "testdata/slip1s.txt", ""},
+		{"", "testdata/slip5s.txt", ""},
+		{"alpha", "testdata/slip1s.txt", "testdata/slabels1s-alpha.txt"},
+		{"alpha", "testdata/slip6s.txt", ""},
+		{"dash", "testdata/slip4s.txt", ""},
+		{"dash", "testdata/slip6s.txt", ""},
+		{"gm", "testdata/slip5s.txt", "testdata/slabels5s-gm.txt"},
+		{"G{g}/S{m:02}/W{w}", "testdata/slip6s.txt", ""},
+	}
+
+	for _, tc := range tests {
+		data, err := ioutil.ReadFile(tc.sharesTf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares := string(data)
+
+		var labelled bytes.Buffer
+		ctx := Context{
+			reader: strings.NewReader(shares),
+			writer: &labelled,
+		}
+		err = SlipLabelCmd{Labels: tc.scheme}.Run(&ctx)
+		if err != nil {
+			t.Errorf("SlipLabel %q error on %q: %s", tc.scheme, tc.sharesTf, err.Error())
+			continue
+		}
+		if tc.labelsTf != "" {
+			ldata, err := ioutil.ReadFile(tc.labelsTf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if labelled.String() != string(ldata) {
+				t.Errorf("SlipLabel %q on %q mismatch - got:\n%sexpected:\n%s",
+					tc.scheme, tc.sharesTf, labelled.String(), string(ldata))
+			}
+		}
+
+		var out bytes.Buffer
+		ctx = Context{
+			reader: &labelled,
+			writer: &out,
+		}
+		err = LabelSlipCmd{Labels: tc.scheme}.Run(&ctx)
+		if err != nil {
+			t.Errorf("LabelSlip %q error on %q: %s", tc.scheme, tc.sharesTf, err.Error())
+			continue
+		}
+		if out.String() != shares {
+			t.Errorf("LabelSlip %q round-trip mismatch on %q - got:\n%sexpected:\n%s",
+				tc.scheme, tc.sharesTf, out.String(), shares)
+		}
+	}
+
+	// The default numeric scheme should round-trip existing labelled files
+	for _, lf := range []string{"testdata/slabels1s.txt", "testdata/slabels2s.txt"} {
+		labels := readTestFile(t, lf)
+		var shares, out bytes.Buffer
+		ctx := Context{
+			reader: strings.NewReader(labels),
+			writer: &shares,
+		}
+		if err := (LabelSlipCmd{}).Run(&ctx); err != nil {
+			t.Errorf("LabelSlip error on %q: %s", lf, err.Error())
+			continue
+		}
+		ctx = Context{
+			reader: &shares,
+			writer: &out,
+		}
+		if err := (SlipLabelCmd{Labels: "numeric"}).Run(&ctx); err != nil {
+			t.Errorf("SlipLabel error on %q shares: %s", lf, err.Error())
+			continue
+		}
+		if out.String() != labels {
+			t.Errorf("numeric round-trip mismatch on %q - got:\n%s", lf, out.String())
+		}
+	}
+
+	// A scheme without {g} can't label multiple groups
+	ctx := Context{
+		reader: strings.NewReader(readTestFile(t, "testdata/slip6s.txt")),
+		writer: io.Discard,
+	}
+	if err := (SlipLabelCmd{Labels: "{m}-{w}"}).Run(&ctx); err == nil {
+		t.Errorf("SlipLabel with no {g} field on multiple groups unexpectedly succeeded")
+	}
+}
+
+// Test converting good labelled word sets (in any order) to BIP39 seeds
+func TestLabelBip_Success(t *testing.T) {
+	t.Parallel()
+
+	// Load all testdata `blabelsMs*.txt` files (good labelled words)
+	testfiles, err := filepath.Glob("testdata/blabels?s*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reTestfile := regexp.MustCompile(`^testdata/blabels(\d+)s.*$`)
+	for _, lf := range testfiles {
+		tf := reTestfile.ReplaceAllString(lf, "testdata/bip${1}s.txt")
+		seed := standardiseMnemonicBytes([]byte(readTestFile(t, tf)))
+
+		var buf bytes.Buffer
+		ctx := Context{
+			reader: strings.NewReader(readTestFile(t, lf)),
+			writer: &buf,
+		}
+		err := LabelBipCmd{}.Run(&ctx)
+		if err != nil {
+			t.Errorf("LabelBip error on %q: %s", lf, err.Error())
+			continue
+		}
+		if got := strings.TrimSpace(buf.String()); got != seed {
+			t.Errorf("LabelBip on %q: got %q, want %q", lf, got, seed)
+		}
+	}
+}
+
+// Test converting bad labelled word sets to BIP39 seeds
+func TestLabelBip_Failure(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"testdata/blabels1f.txt": `duplicate label "05" on line 5 and line 6 (repeated); duplicate label "09" on line 7 and line 11 ("guard" vs "behind")`,
+		"testdata/blabels2f.txt": `missing word with label "03"; missing word with label "07"`,
+		"testdata/blabels3f.txt": "invalid BIP-39 mnemonic (bad checksum word?)",
+		"testdata/blabels4f.txt": `missing word with label "23"; missing word with label "24"`,
+	}
+
+	for lf, want := range tests {
+		ctx := Context{
+			reader: strings.NewReader(readTestFile(t, lf)),
+			writer: io.Discard,
+		}
+		err := LabelBipCmd{}.Run(&ctx)
+		if err == nil {
+			t.Errorf("LabelBip on %q unexpectedly succeeded!", lf)
+			continue
+		}
+		if err.Error() != want {
+			t.Errorf("LabelBip on %q: got error %q, want %q", lf, err.Error(), want)
+		}
+	}
+}
+
+func TestLabelBip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		scheme   string
+		labelsTf string
+	}{
+		{"", "testdata/blabels1s.txt"},
+		{"dash", "testdata/blabels-dash1s.txt"},
+	}
+
+	seed := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip1s.txt")))
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		ctx := Context{
+			reader: strings.NewReader(readTestFile(t, tc.labelsTf)),
+			writer: &buf,
+		}
+		err := LabelBipCmd{Labels: tc.scheme}.Run(&ctx)
+		if err != nil {
+			t.Errorf("LabelBip %q error on %q: %s", tc.scheme, tc.labelsTf, err.Error())
+			continue
+		}
+		if got := strings.TrimSpace(buf.String()); got != seed {
+			t.Errorf("LabelBip %q on %q: got %q, want %q", tc.scheme, tc.labelsTf, got, seed)
+		}
+
+		// Bip labelling with the same scheme should reproduce the labels
+		var labelled bytes.Buffer
+		ctx = Context{
+			reader: strings.NewReader(seed),
+			writer: &labelled,
+		}
+		if err := (BipLabelCmd{Upper: true, Labels: tc.scheme}).Run(&ctx); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.EqualFold(labelled.String(), readTestFile(t, tc.labelsTf)) {
+			t.Errorf("BipLabel %q mismatch - got:\n%s", tc.scheme, labelled.String())
+		}
+	}
+}
+
+func readTestFile(t *testing.T, filename string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// Test converting out-of-order and partial labelled word sets
+func TestLabelSlip_Partial(t *testing.T) {
+	t.Parallel()
+
+	var want bytes.Buffer
+	ctx := Context{
+		reader: strings.NewReader(readTestFile(t, "testdata/slabels1s.txt")),
+		writer: &want,
+	}
+	if err := (LabelSlipCmd{}).Run(&ctx); err != nil {
+		t.Fatal(err)
+	}
+	shares := strings.Split(strings.TrimSpace(want.String()), "\n")
+
+	// Shuffled labels should produce the same shares
+	var got bytes.Buffer
+	ctx = Context{
+		reader: strings.NewReader(readTestFile(t, "testdata/slabels1s-shuffled.txt")),
+		writer: &got,
+	}
+	if err := (LabelSlipCmd{}).Run(&ctx); err != nil {
+		t.Errorf("LabelSlip on shuffled labels failed: %s", err.Error())
+	}
+	if diff := cmp.Diff(want.String(), got.String()); diff != "" {
+		t.Errorf("shuffled labels mismatch (-want +got):\n%s", diff)
+	}
+
+	// Bad shares should be reported, and complete shares still emitted
+	got.Reset()
+	ctx = Context{
+		reader: strings.NewReader(readTestFile(t, "testdata/slabels9f.txt")),
+		writer: &got,
+	}
+	err := LabelSlipCmd{}.Run(&ctx)
+	if err == nil {
+		t.Fatal("LabelSlip on slabels9f.txt unexpectedly succeeded!")
+	}
+	if got.String() != shares[3]+"\n" {
+		t.Errorf("expected complete share 4 to be output, got:\n%s", got.String())
+	}
+	for _, problem := range []string{
+		"1 of 4 labelled shares complete",
+		`share 1: line 2, field 2: rejected word "bitcoin" with label "112" (not in the SLIP-39 wordlist)`,
+		`share 2: missing word with label "205"`,
+		`share 3: duplicate label "310" on line 110 and line 111 (repeated)`,
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected error to include %q, got: %s", problem, err.Error())
+		}
+	}
+}
+
+func TestNumericLabels(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		label string
+		pos   labelPosition
+	}{
+		{"101", labelPosition{1, 1, 1}},
+		{"320", labelPosition{1, 3, 20}},
+		{"1101", labelPosition{1, 1, 1}},
+		{"2433", labelPosition{2, 4, 33}},
+		{"0412", labelPosition{1, 4, 12}},
+		{"1001", labelPosition{1, 10, 1}},
+		{"1020", labelPosition{1, 10, 20}},
+		{"01220", labelPosition{1, 2, 20}},
+		{"11220", labelPosition{1, 12, 20}},
+		{"111220", labelPosition{11, 12, 20}},
+	}
+
+	for _, tc := range tests {
+		lt, err := newNumericLabelTemplate(len(tc.label))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pos, err := lt.parse(tc.label)
+		if err != nil {
+			t.Errorf("parse %q failed: %s", tc.label, err.Error())
+			continue
+		}
+		if pos != tc.pos {
+			t.Errorf("parse %q: got %v, want %v", tc.label, pos, tc.pos)
+		}
+		// Some positions have more than one valid label, so just check that
+		// formatted labels parse back to the same position
+		label := lt.format(pos)
+		if pos2, err := lt.parse(label); err != nil || pos2 != pos {
+			t.Errorf("format %v: got %q, which doesn't parse back (%v)", pos, label, err)
+		}
+	}
+}
+
+// Test round-tripping a single group of more than 9 members, whose numeric
+// labels all use the alternate widths (0101 ... 1633)
+func TestSlipLabel_ManyMembers(t *testing.T) {
+	t.Parallel()
+
+	mnemonic := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip1s.txt")))
+	var buf bytes.Buffer
+	cmd := BipSlipCmd{
+		GroupThreshold: 1,
+		Groups:         []string{"2of16"},
+		Seed:           strings.Fields(mnemonic),
+	}
+	if err := cmd.Run(&Context{writer: &buf, errWriter: io.Discard}); err != nil {
+		t.Fatalf("bs -g 2of16 failed: %s", err.Error())
+	}
+	shares := buf.String()
+
+	var words bytes.Buffer
+	ctx := Context{reader: strings.NewReader(shares), writer: &words, errWriter: io.Discard}
+	if err := (SlipLabelCmd{}).Run(&ctx); err != nil {
+		t.Fatalf("sl failed: %s", err.Error())
+	}
+	for _, label := range []string{"0101 ", "1001 ", "1101 ", "1633 "} {
+		if !strings.Contains(words.String(), "\n"+label) && !strings.HasPrefix(words.String(), label) {
+			t.Errorf("sl output has no label %q:\n%s", label, words.String())
+		}
+	}
+
+	var out bytes.Buffer
+	ctx = Context{reader: &words, writer: &out, errWriter: io.Discard}
+	if err := (LabelSlipCmd{}).Run(&ctx); err != nil {
+		t.Fatalf("ls failed: %s", err.Error())
+	}
+	if out.String() != shares {
+		t.Errorf("round-trip mismatch - got:\n%sexpected:\n%s", out.String(), shares)
+	}
+}
+
+func TestVerifyTranscript(t *testing.T) {
+	t.Parallel()
+
+	slabels := readTestFile(t, "testdata/slabels5s-gm.txt")
+	blabels := readTestFile(t, "testdata/blabels1s.txt")
+	tests := []struct {
+		original   string
+		labels     string
+		transcript string
+		want       []string
+	}{
+		// Good transcripts
+		{"testdata/bip1s.txt", "", readTestFile(t, "testdata/bip1sn.txt"), nil},
+		{"testdata/bip1s.txt", "", blabels, nil},
+		{"testdata/slip1s.txt", "", readTestFile(t, "testdata/slip1sb.txt"), nil},
+		{"testdata/slip5s.txt", "gm", slabels, nil},
+		// Bad transcripts
+		{"testdata/bip1s.txt", "", strings.Replace(blabels, "HOUR", "HOUSE", 1),
+			[]string{`word 2 (label "02"): expected "hour", got "house"`}},
+		{"testdata/bip1s.txt", "", "all hour make first leader extend hole alien behind guard gospel lava path output census museum junior mass reopen famous sing advance salt",
+			[]string{`word 24: expected "reform", got "(missing)"`}},
+		{"testdata/bip1s.txt", "", "all hour make first",
+			[]string{"too many mismatches", `word 5: expected "(hidden)", got "(missing)"`}},
+		{"testdata/slip5s.txt", "gm", strings.Replace(slabels, "G1M1-02 agency", "G1M1-02 agenda", 1),
+			[]string{`group 1 share 1 word 2 (label "G1M1-02"): expected "agency", got "agenda"`}},
+		{"testdata/slip5s.txt", "gm", slabels + "G3M1-01 easel\n",
+			[]string{`extra group 3 share 1: expected "(none)", got "1 words"`}},
+	}
+
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		ctx := Context{
+			reader: strings.NewReader(tc.transcript),
+			writer: &buf,
+		}
+		cmd := VerifyTranscriptCmd{Original: tc.original, Labels: tc.labels}
+		err := cmd.Run(&ctx)
+		if tc.want == nil {
+			if err != nil {
+				t.Errorf("VerifyTranscript on %q failed: %s", tc.original, err.Error())
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("VerifyTranscript on %q unexpectedly succeeded: %s",
+				tc.original, buf.String())
+			continue
+		}
+		out := buf.String()
+		for _, want := range tc.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("VerifyTranscript on %q: expected %q in output, got:\n%s",
+					tc.original, want, out)
+			}
+		}
+
+		// The secret should never be output in full
+		words := strings.Fields(readTestFile(t, tc.original))
+		revealed := 0
+		for _, word := range words {
+			revealed += strings.Count(out, fmt.Sprintf("expected %q", word))
+		}
+		if revealed > len(words)/transcriptRevealRatio {
+			t.Errorf("VerifyTranscript on %q revealed %d expected words:\n%s",
+				tc.original, revealed, out)
+		}
+	}
+}
+
+func TestSecretBuffer(t *testing.T) {
+	t.Parallel()
+
+	isZero := func(b []byte) bool {
+		for _, c := range b {
+			if c != 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Read enough to force buffer growth, and check outgrown buffers are wiped
+	input := strings.Repeat("abandon ", secretReadSize)
+	reader := &recordingReader{r: strings.NewReader(input)}
+	sb, err := readSecret(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sb.Bytes()) != input {
+		t.Fatalf("readSecret returned %d bytes, expected %d", len(sb.Bytes()), len(input))
+	}
+	if len(reader.bufs) < 2 {
+		t.Fatalf("expected readSecret to grow its buffer, but it read into %d", len(reader.bufs))
+	}
+	for i, buf := range reader.bufs[:len(reader.bufs)-1] {
+		if !isZero(buf[:cap(buf)]) {
+			t.Errorf("outgrown read buffer %d was not wiped", i)
+		}
+	}
+
+	buf := sb.Bytes()
+	sb.Wipe()
+	if !isZero(buf) {
+		t.Errorf("secretBuffer.Wipe did not clear its buffer")
+	}
+	if sb.Bytes() != nil {
+		t.Errorf("secretBuffer.Wipe did not release its buffer")
+	}
+
+	entropy := []byte{1, 2, 3, 4}
+	passphrase := []byte("TREZOR")
+	wipeBytes(entropy, passphrase)
+	if !isZero(entropy) || !isZero(passphrase) {
+		t.Errorf("wipeBytes did not clear buffers: %v %v", entropy, passphrase)
+	}
+
+	n, _ := new(big.Int).SetString("deadbeefdeadbeefdeadbeefdeadbeef", 16)
+	words := n.Bits()
+	wipeBigInt(n)
+	for _, w := range words {
+		if w != 0 {
+			t.Errorf("wipeBigInt did not clear words: %v", words)
+			break
+		}
+	}
+	if n.Sign() != 0 {
+		t.Errorf("wipeBigInt did not zero value: %s", n.String())
+	}
+
+	key, err := newMasterKey(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, chainCode := key.key, key.chainCode
+	key.wipe()
+	if !isZero(keyBytes) || !isZero(chainCode) {
+		t.Errorf("extendedKey.wipe did not clear key material")
+	}
+}
+
+// TestSecretCallers checks the commands' input paths read via secretBuffers,
+// wiping the buffers read into, and return the same input as before
+func TestSecretCallers(t *testing.T) {
+	t.Parallel()
+
+	wiped := func(reader *recordingReader) bool {
+		for _, buf := range reader.bufs {
+			for _, c := range buf[:cap(buf)] {
+				if c != 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	slip := readTestFile(t, "testdata/slip1s.txt")
+	shares := strings.Split(strings.TrimSpace(slip), "\n")
+	bip := strings.TrimSpace(readTestFile(t, "testdata/bip1s.txt"))
+
+	// Stdin
+	reader := &recordingReader{r: strings.NewReader(slip)}
+	mnemonics, err := readShareMnemonics(&Context{reader: reader}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(shares, mnemonics); diff != "" {
+		t.Errorf("readShareMnemonics from stdin mismatch (-want +got):\n%s", diff)
+	}
+	if !wiped(reader) {
+		t.Errorf("readShareMnemonics did not wipe its stdin buffer")
+	}
+	reader = &recordingReader{r: strings.NewReader(bip + "\n")}
+	mnemonic, err := readSeedMnemonic(&Context{reader: reader}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mnemonic != bip {
+		t.Errorf("readSeedMnemonic from stdin returned %q, expected %q", mnemonic, bip)
+	}
+	if !wiped(reader) {
+		t.Errorf("readSeedMnemonic did not wipe its stdin buffer")
+	}
+
+	// Files, plaintext and via the decrypter
+	for _, ctx := range []*Context{{}, {decrypter: &shareDecrypter{}}} {
+		mnemonics, err = readShareMnemonics(ctx, nil, []string{"testdata/slip1s.txt"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(shares, mnemonics); diff != "" {
+			t.Errorf("readShareMnemonics from file (decrypter %v) mismatch (-want +got):\n%s",
+				ctx.decrypter != nil, diff)
+		}
+	}
+	mnemonic, err = readSeedMnemonicFromFile(&Context{}, "testdata/bip1s.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mnemonic != bip {
+		t.Errorf("readSeedMnemonicFromFile returned %q, expected %q", mnemonic, bip)
+	}
+
+	// verify-transcript with both original and transcript files
+	var buf bytes.Buffer
+	cmd := VerifyTranscriptCmd{Original: "testdata/bip1s.txt", Transcript: "testdata/bip1sn.txt"}
+	if err := cmd.Run(&Context{writer: &buf}); err != nil {
+		t.Errorf("VerifyTranscript from files failed: %s\n%s", err.Error(), buf.String())
+	}
+	cmd = VerifyTranscriptCmd{Original: "testdata/missing.txt", Transcript: "testdata/bip1sn.txt"}
+	if err := cmd.Run(&Context{writer: &buf}); err == nil || !strings.Contains(err.Error(), "reading original") {
+		t.Errorf("VerifyTranscript with a missing original returned %v, expected a reading error", err)
+	}
+}
+
+// recordingReader records the buffers it is asked to read into
+type recordingReader struct {
+	r    io.Reader
+	bufs [][]byte
+}
+
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	if len(rr.bufs) == 0 || &rr.bufs[len(rr.bufs)-1][:1][0] != &p[:1][0] {
+		rr.bufs = append(rr.bufs, p)
+	}
+	return rr.r.Read(p)
+}
+
+func TestBip39ChecksumValid(t *testing.T) {
+	t.Parallel()
+
+	mnemonics := []string{
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo",
+		standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip1s.txt"))),
+		"abandon abandon abandon",
+	}
+
+	for _, m := range mnemonics {
+		want := bip39.IsMnemonicValid(m)
+		if got := bip39ChecksumValid(strings.Fields(m)); got != want {
+			t.Errorf("bip39ChecksumValid(%q) = %v, want %v", m, got, want)
+		}
+		if !want {
+			continue
+		}
+		entropy, err := bip39EntropyFromWords(strings.Fields(m))
+		if err != nil {
+			t.Errorf("bip39EntropyFromWords(%q) failed: %s", m, err.Error())
+			continue
+		}
+		wantEntropy, _ := bip39.EntropyFromMnemonic(m)
+		if !bytes.Equal(entropy, wantEntropy) {
+			t.Errorf("bip39EntropyFromWords(%q) = %x, want %x", m, entropy, wantEntropy)
+		}
+	}
+}
+
+func TestDoctorChecks(t *testing.T) {
+	t.Parallel()
+
+	// Build fake system roots
+	writeFiles := func(root string, files map[string]string) {
+		for name, content := range files {
+			path := filepath.Join(root, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	swapHeader := "Filename\tType\tSize\tUsed\tPriority\n"
+	safe := t.TempDir()
+	writeFiles(safe, map[string]string{
+		"/sys/class/net/lo/operstate":   "unknown\n",
+		"/sys/class/net/lo/carrier":     "1\n",
+		"/sys/class/net/eth0/operstate": "down\n",
+		"/proc/swaps":                   swapHeader,
+		"/etc/os-release":               "NAME=\"Tails\"\nID=\"tails\"\n",
+	})
+	unsafe := t.TempDir()
+	writeFiles(unsafe, map[string]string{
+		"/sys/class/net/lo/operstate":    "unknown\n",
+		"/sys/class/net/eth0/operstate":  "up\n",
+		"/sys/class/net/wlan0/operstate": "unknown\n",
+		"/sys/class/net/wlan0/carrier":   "1\n",
+		"/proc/swaps":                    swapHeader + "/dev/sda2\tpartition\t8388604\t0\t-2\n",
+		"/etc/os-release":                "NAME=\"Debian GNU/Linux\"\nID=debian\n",
+	})
+
+	noCoreDumps := func() (uint64, error) { return 0, nil }
+	coreDumps := func() (uint64, error) { return 1 << 20, nil }
+	tests := []struct {
+		env  doctorEnv
+		want map[string]int
+	}{
+		{doctorEnv{root: safe, goos: "linux", stdoutTTY: true, coreLimit: noCoreDumps},
+			map[string]int{"network": checkOK, "swap": checkOK, "core dumps": checkOK,
+				"stdout": checkOK, "tails": checkOK}},
+		{doctorEnv{root: unsafe, goos: "linux", stdoutTTY: false, coreLimit: coreDumps},
+			map[string]int{"network": checkFail, "swap": checkWarn, "core dumps": checkWarn,
+				"stdout": checkWarn, "tails": checkWarn}},
+		{doctorEnv{root: t.TempDir(), goos: "darwin", stdoutTTY: true, coreLimit: noCoreDumps},
+			map[string]int{"platform": checkWarn, "stdout": checkOK}},
+	}
+
+	for i, tc := range tests {
+		got := map[string]int{}
+		for _, check := range runDoctorChecks(tc.env) {
+			got[check.name] = check.status
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("test %d check status mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := paranoidCheck(&buf, "bs", tests[1].env)
+	if err == nil {
+		t.Errorf("paranoidCheck unexpectedly passed in unsafe environment")
+	}
+	if !strings.Contains(buf.String(), "wlan0") {
+		t.Errorf("paranoidCheck didn't report up interfaces: %s", buf.String())
+	}
+	if err := paranoidCheck(io.Discard, "bs", tests[0].env); err != nil {
+		t.Errorf("paranoidCheck failed in safe environment: %s", err.Error())
+	}
+	if err := paranoidCheck(io.Discard, "version", tests[1].env); err != nil {
+		t.Errorf("paranoidCheck failed for non-secret command: %s", err.Error())
+	}
+	if err := paranoidCheck(io.Discard, "verify-fingerprint", tests[1].env); err == nil {
+		t.Errorf("paranoidCheck unexpectedly passed verify-fingerprint in unsafe environment")
+	}
+	for command, secret := range map[string]bool{
+		"policy explain <shares>": true,
+		"policy explain":          true,
+		"policy analyze":          false,
+		"bs <seed>":               true,
+	} {
+		err := paranoidCheck(io.Discard, commandPath(command), tests[1].env)
+		if secret && err == nil {
+			t.Errorf("paranoidCheck unexpectedly passed %q in unsafe environment", command)
+		} else if !secret && err != nil {
+			t.Errorf("paranoidCheck failed for non-secret command %q: %s", command, err.Error())
+		}
+	}
+}
+
+// promptScript answers interactive prompts based on the output so far,
+// retyping each displayed share (with one typo in the first attempt)
+type promptScript struct {
+	out   *bytes.Buffer
+	typos int
+}
+
+var ansiRE = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+var displayedWordRE = regexp.MustCompile(`(\d+)\. ([A-Z]+)`)
+var retypePromptRE = regexp.MustCompile(`Type back share (\d+) of \d+`)
+
+func (cs *promptScript) Read(p []byte) (int, error) {
+	output := ansiRE.ReplaceAllString(cs.out.String(), "")
+	lines := strings.Split(output, "\n")
+	prompt := lines[len(lines)-1]
+	answer := ""
+	switch {
+	case strings.HasPrefix(prompt, "Wallet name"):
+		answer = "Cicero"
+	case strings.HasPrefix(prompt, "Number of words"):
+		// Try an invalid mnemonic length first
+		answer = "13"
+		if strings.Contains(output, "please enter 12, 15, 18, 21, or 24") {
+			answer = "12"
+		}
+	case strings.HasPrefix(prompt, "Optional dice rolls"):
+		answer = "3 1 4 1 5 2 6 5"
+	case strings.HasPrefix(prompt, "Display the BIP-39"):
+		answer = "n"
+	case strings.HasPrefix(prompt, "Share groups"):
+		answer = "2of3 1of1"
+	case strings.HasPrefix(prompt, "Group threshold"):
+		answer = "2"
+	case strings.HasSuffix(prompt, "press Enter..."),
+		strings.HasSuffix(prompt, "press Enter to clear the screen..."):
+	case retypePromptRE.MatchString(prompt):
+		index := retypePromptRE.FindStringSubmatch(prompt)[1]
+		words := displayedShareWords(output, index)
+		if cs.typos > 0 {
+			cs.typos--
+			words[2] = "typo"
+		}
+		answer = strings.Join(words, " ")
+	default:
+		return 0, fmt.Errorf("unexpected prompt %q", prompt)
+	}
+	// Echo the answer, as a terminal would
+	cs.out.WriteString(answer + "\n")
+	return copy(p, answer+"\n"), nil
+}
+
+// displayedShareWords returns the words of the last display of share index
+// in output
+func displayedShareWords(output, index string) []string {
+	displays := strings.Split(output, "Share "+index+" of ")
+	display, _, _ := strings.Cut(displays[len(displays)-1], "Record these words")
+	words := []string{}
+	for _, m := range displayedWordRE.FindAllStringSubmatch(display, -1) {
+		var n int
+		fmt.Sscan(m[1], &n)
+		for len(words) < n {
+			words = append(words, "")
+		}
+		words[n-1] = strings.ToLower(m[2])
+	}
+	return words
+}
+
+func TestCeremony(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	ctx := &Context{writer: &buf}
+	ctx.reader = &promptScript{out: &buf, typos: 1}
+	err := CeremonyCmd{}.Run(ctx)
+	if err != nil {
+		t.Fatalf("ceremony failed: %s\n%s", err.Error(), buf.String())
+	}
+	output := ansiRE.ReplaceAllString(buf.String(), "")
+
+	if !strings.Contains(output, "Warning: please enter 12, 15, 18, 21, or 24") {
+		t.Errorf("ceremony didn't warn about an invalid mnemonic length:\n%s", output)
+	}
+	if !strings.Contains(output, "Mixing in 8 dice rolls") {
+		t.Errorf("ceremony didn't report dice rolls:\n%s", output)
+	}
+	// Twice for each share displayed, and once after each share re-entered
+	if got := strings.Count(buf.String(), clearScreenSeq); got != 12 {
+		t.Errorf("ceremony cleared the screen %d times, expected 12", got)
+	}
+	// Each custodian's re-entry is cleared before the next is handed over
+	_, retyping, _ := strings.Cut(buf.String(), "Now re-enter each share")
+	for i := 2; i <= 4; i++ {
+		before, _, _ := strings.Cut(retyping, fmt.Sprintf("Pass to the custodian of share %d of 4", i))
+		if !strings.HasSuffix(before, clearScreenSeq) {
+			t.Errorf("ceremony didn't clear the screen before passing to custodian %d", i)
+		}
+	}
+	for _, title := range []string{
+		"Share 1 of 4: Cicero, Group 1, Share 1, 2of3, Threshold 2",
+		"Share 4 of 4: Cicero, Group 2, 1of1, Threshold 2",
+	} {
+		if !strings.Contains(output, title) {
+			t.Errorf("ceremony didn't display %q", title)
+		}
+	}
+	if !strings.Contains(output, "word 3 does not match") {
+		t.Errorf("ceremony didn't report retyped mismatch")
+	}
+	if !strings.Contains(output, "Ceremony complete - 4 shares recorded and verified") {
+		t.Errorf("ceremony didn't complete:\n%s", output)
+	}
+}
+
+func TestBipSlip_OneAtATime(t *testing.T) {
+	t.Parallel()
+
+	mnemonic := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip1s.txt")))
+	for _, retype := range []bool{false, true} {
+		cmd := BipSlipCmd{
+			GroupThreshold: 1,
+			Groups:         []string{"2of3"},
+			OneAtATime:     true,
+			Retype:         retype,
+			Seed:           strings.Fields(mnemonic),
+		}
+		var buf bytes.Buffer
+		ctx := &Context{writer: &buf}
+		ctx.reader = &promptScript{out: &buf, typos: 1}
+		if err := cmd.Run(ctx); err != nil {
+			t.Fatalf("bs --one-at-a-time failed: %s\n%s", err.Error(), buf.String())
+		}
+		output := ansiRE.ReplaceAllString(buf.String(), "")
+
+		clears := 6
+		if retype {
+			clears = 9
+			if !strings.Contains(output, "word 3 does not match") {
+				t.Errorf("bs --retype didn't report retyped mismatch")
+			}
+		}
+		if got := strings.Count(buf.String(), clearScreenSeq); got != clears {
+			t.Errorf("bs --one-at-a-time (retype %t) cleared the screen %d times, expected %d",
+				retype, got, clears)
+		}
+
+		// The displayed shares should combine to the original mnemonic
+		shares := []string{}
+		for _, index := range []string{"1", "3"} {
+			shares = append(shares, strings.Join(displayedShareWords(output, index), " "))
+		}
+		buf.Reset()
+		if err := (SlipBipCmd{Shares: shares}).Run(&Context{writer: &buf}); err != nil {
+			t.Fatalf("combining displayed shares failed: %s", err.Error())
+		}
+		if got := strings.TrimSpace(buf.String()); got != mnemonic {
+			t.Errorf("displayed shares combine to %q, expected %q", got, mnemonic)
+		}
+	}
+}
+
+func TestBipSlip_Encrypted(t *testing.T) {
+	t.Parallel()
+
+	mnemonic := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip1s.txt")))
+	age2 := strings.TrimSpace(readTestFile(t, "testdata/age2-recipient.txt"))
+	dir := t.TempDir()
+	cmd := BipSlipCmd{
+		GroupThreshold: 1,
+		Groups:         []string{"2of4"},
+		EncryptTo: []string{
+			"testdata/age1-recipient.txt",
+			age2,
+			"testdata/pgp1-public.asc",
+			"testdata/pgp2-public.asc",
+		},
+		OutputDir: dir,
+		Seed:      strings.Fields(mnemonic),
+	}
+	var buf bytes.Buffer
+	if err := cmd.Run(&Context{writer: &buf}); err != nil {
+		t.Fatalf("bs --encrypt-to failed: %s", err.Error())
+	}
+	filenames := []string{"share-g1-m1.age", "share-g1-m2.age", "share-g1-m3.asc", "share-g1-m4.asc"}
+	for i, filename := range filenames {
+		filenames[i] = filepath.Join(dir, filename)
+		data, err := ioutil.ReadFile(filenames[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), strings.Fields(mnemonic)[0]+" ") ||
+			!strings.HasPrefix(string(data), "-----BEGIN ") {
+			t.Errorf("share file %q is not armored ciphertext", filename)
+		}
+	}
+
+	// Existing share files are never overwritten
+	if err := cmd.Run(&Context{writer: io.Discard}); err == nil {
+		t.Errorf("bs --encrypt-to overwrote existing share files")
+	}
+	cmd.EncryptTo = cmd.EncryptTo[:3]
+	cmd.OutputDir = t.TempDir()
+	if err := cmd.Run(&Context{writer: io.Discard}); err == nil ||
+		!strings.Contains(err.Error(), "3 recipients given for 4 shares") {
+		t.Errorf("bs --encrypt-to with too few recipients returned %v", err)
+	}
+
+	tests := []struct {
+		files      []string
+		identities []string
+		keyPass    string
+		errstr     string
+	}{
+		{filenames[:2], []string{"testdata/age1-identity.txt", "testdata/age2-identity.txt"}, "", ""},
+		{filenames[1:3], []string{"testdata/age2-identity.txt", "testdata/pgp1-secret.asc"}, "", ""},
+		{filenames[2:], []string{"testdata/pgp1-secret.asc", "testdata/pgp2-secret.asc"}, "TREZOR", ""},
+		{filenames[2:], []string{"testdata/pgp1-secret.asc", "testdata/pgp2-secret.asc"}, "",
+			"passphrase-protected"},
+		{filenames[:2], []string{"testdata/age1-identity.txt", "testdata/pgp1-secret.asc"}, "",
+			"no identity matched"},
+		{filenames[1:3], []string{"testdata/age2-identity.txt"}, "",
+			"no OpenPGP secret keys given"},
+	}
+	for i, tc := range tests {
+		var buf bytes.Buffer
+		cmd := SlipBipCmd{Files: tc.files, Identities: tc.identities, KeyPass: tc.keyPass}
+		err := cmd.Run(&Context{writer: &buf})
+		if tc.errstr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.errstr) {
+				t.Errorf("test %d: expected error containing %q, got %v", i, tc.errstr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: sb with encrypted shares failed: %s", i, err.Error())
+			continue
+		}
+		if got := strings.TrimSpace(buf.String()); got != mnemonic {
+			t.Errorf("test %d: decrypted shares combine to %q, expected %q", i, got, mnemonic)
+		}
+	}
+}
+
+// TestBipSlip_Deterministic is not parallel, since --deterministic-seed
+// replaces the process-global crypto/rand.Reader
+func TestBipSlip_Deterministic(t *testing.T) {
+	mnemonic := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip1s.txt")))
+	want := readTestFile(t, "testdata/deterministic1s.txt")
+	seed := "000102030405060708090a0b0c0d0e0f"
+
+	var stderr bytes.Buffer
+	run := func(seed string) (string, error) {
+		cmd := BipSlipCmd{
+			GroupThreshold: 2,
+			Groups:         []string{"2of3", "3of5"},
+			DetSeed:        seed,
+			Seed:           strings.Fields(mnemonic),
+		}
+		var buf bytes.Buffer
+		stderr.Reset()
+		err := cmd.Run(&Context{writer: &buf, errWriter: &stderr})
+		return buf.String(), err
+	}
+
+	for range 2 {
+		got, err := run(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("deterministic shares mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	}
+	// The warning records the versions needed to reproduce the shares
+	versions := fmt.Sprintf("(seedkit %s, go-slip39 v", version)
+	if !strings.Contains(stderr.String(), versions) {
+		t.Errorf("deterministic warning doesn't include %q:\n%s", versions, stderr.String())
+	}
+	got, err := run(seed + "00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == want {
+		t.Errorf("different deterministic seeds generated identical shares")
+	}
+	if _, err := run("000102030405060708090a0b0c0d0e"); err == nil {
+		t.Errorf("short deterministic seed was accepted")
+	}
+	if _, err := run("not hex"); err == nil {
+		t.Errorf("non-hex deterministic seed was accepted")
+	}
+
+	// Deterministic shares must still combine to the original mnemonic
+	var buf bytes.Buffer
+	err = SlipValCmd{CheckFile: "testdata/bip1s.txt", Shares: strings.Split(strings.TrimSpace(want), "\n")}.
+		Run(&Context{writer: &buf})
+	if err != nil {
+		t.Errorf("deterministic shares are invalid: %s", err.Error())
+	}
+}
+
+func TestSelectChecksumWord(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		n             int
+		choice        int
+		deterministic bool
+		want          int
+		errstr        string
+	}{
+		{128, 1, false, 0, ""},
+		{128, 128, false, 127, ""},
+		{8, 5, false, 4, ""},
+		{8, 0, true, 0, ""},
+		{8, 9, false, 0, "must be from 1 to 8"},
+		{128, -1, false, 0, "must be from 1 to 128"},
+	}
+	for _, tc := range tests {
+		got, err := selectChecksumWord(tc.n, tc.choice, tc.deterministic)
+		if tc.errstr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.errstr) {
+				t.Errorf("selectChecksumWord(%d, %d) expected error %q, got %v",
+					tc.n, tc.choice, tc.errstr, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("selectChecksumWord(%d, %d, %t) returned %d, %v, expected %d",
+				tc.n, tc.choice, tc.deterministic, got, err, tc.want)
+		}
+	}
+
+	// Random selections should be in range, and cover all of a small set
+	seen := map[int]bool{}
+	for range 1000 {
+		i, err := selectChecksumWord(8, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < 0 || i >= 8 {
+			t.Fatalf("selectChecksumWord returned out of range index %d", i)
+		}
+		seen[i] = true
+	}
+	if len(seen) != 8 {
+		t.Errorf("random selectChecksumWord only chose %d of 8 indices", len(seen))
+	}
+}
+
+func TestBipCheckword_Failure(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{10, 12, 13, 15, 21, 22, 24} {
+		cmd := BipCheckwordCmd{
+			Deterministic:   true,
+			PartialMnemonic: strings.Fields(strings.Repeat("abandon ", n)),
+		}
+		err := cmd.Run(&Context{writer: io.Discard, errWriter: io.Discard})
+		if err == nil || !strings.Contains(err.Error(), "invalid mnemonic seed length") {
+			t.Errorf("%d-word partial mnemonic returned %v, expected length error", n, err)
+		}
+	}
+}
+
+func TestDedupeShares(t *testing.T) {
+	t.Parallel()
+
+	dupes := readTestFile(t, "testdata/slip2sd.txt")
+	conflicts := readTestFile(t, "testdata/slip5f.txt")
+	// The first two slip5f shares are a valid 2of3 set for bip2s
+	shares := strings.Split(strings.TrimSpace(conflicts), "\n")
+	mnemonic := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip2s.txt")))
+
+	tests := []struct {
+		name   string
+		cmd    interface{ Run(*Context) error }
+		input  string
+		warn   string
+		errstr string
+	}{
+		{"sv", SlipValCmd{}, dupes, "ignoring share 4 - it is a duplicate of share 1", ""},
+		{"sl", SlipLabelCmd{}, dupes, "ignoring share 4 - it is a duplicate of share 1", ""},
+		{"se", SlipEntropyCmd{}, dupes, "ignoring share 4 - it is a duplicate of share 1", ""},
+		{"sb", SlipBipCmd{}, strings.Join([]string{shares[0], shares[1], " " + shares[0]}, "\n"),
+			"ignoring share 3 - it is a duplicate of share 1", ""},
+		{"sv", SlipValCmd{}, conflicts, "", "conflicting shares: shares 1 and 3 are both group 1 member 1"},
+		{"sb", SlipBipCmd{}, conflicts, "", "conflicting shares: shares 1 and 3 are both group 1 member 1"},
+		{"sl", SlipLabelCmd{}, conflicts, "", "conflicting shares: shares 1 and 3 are both group 1 member 1"},
+		{"se", SlipEntropyCmd{}, conflicts, "", "conflicting shares: shares 1 and 3 are both group 1 member 1"},
+	}
+	for _, tc := range tests {
+		var buf, errbuf bytes.Buffer
+		ctx := Context{
+			reader:    strings.NewReader(tc.input),
+			writer:    &buf,
+			errWriter: &errbuf,
+		}
+		err := tc.cmd.Run(&ctx)
+		if tc.errstr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.errstr) {
+				t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.errstr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err.Error())
+			continue
+		}
+		if !strings.Contains(errbuf.String(), tc.warn) {
+			t.Errorf("%s: expected warning %q, got %q", tc.name, tc.warn, errbuf.String())
+		}
+		if tc.name == "sb" && strings.TrimSpace(buf.String()) != mnemonic {
+			t.Errorf("sb: got %q, expected %q", strings.TrimSpace(buf.String()), mnemonic)
+		}
+	}
+}
+
+func TestSlipVal_Diagnose(t *testing.T) {
+	t.Parallel()
+
+	tampered := readTestFile(t, "testdata/slip6f.txt")
+	// The first three slip6f shares are good 2of4 shares for bip2s
+	good := strings.Join(strings.Split(tampered, "\n")[:3], "\n")
+	badChecksum := strings.Replace(strings.Split(tampered, "\n")[3], "artist", "artwork", 1)
+
+	tests := []struct {
+		name    string
+		input   string
+		outputs []string
+		errstr  string
+	}{
+		{"good", good, []string{"Tried 3 share combinations:", "Summary: 3 produced secret A"}, ""},
+		{"good multigroup", readTestFile(t, "testdata/slip8s.txt"),
+			[]string{"group 1 member 1: secret A", "group 2 members 2, 3: secret A"}, ""},
+		{"tampered", tampered,
+			[]string{"group 1 members 1, 4: invalid shared secret digest", "Summary: 3 produced secret A, 3 failed",
+				"likely bad share: group 1 member 4 (input share 4)"},
+			"likely bad share(s): group 1 member 4 (input share 4)"},
+		{"invalid", good + "\n" + badChecksum,
+			[]string{"input share 4 is invalid"}, "likely bad share(s): input share 4"},
+		{"foreign", readTestFile(t, "testdata/slip5f.txt"),
+			[]string{"group 1 members 1, 2: secret A"},
+			"likely bad share(s): group 1 member 1 (input share 3)"},
+	}
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		cmd := SlipValCmd{Diagnose: true}
+		ctx := Context{
+			reader:    strings.NewReader(tc.input),
+			writer:    &buf,
+			errWriter: io.Discard,
+		}
+		err := cmd.Run(&ctx)
+		if tc.errstr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.errstr) {
+				t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.errstr, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err.Error())
+		}
+		for _, output := range tc.outputs {
+			if !strings.Contains(buf.String(), output) {
+				t.Errorf("%s: expected output containing %q, got:\n%s", tc.name, output, buf.String())
+			}
+		}
+	}
+}
+
+func TestPartitionShareSets(t *testing.T) {
+	t.Parallel()
+
+	slip2s := readTestFile(t, "testdata/slip2s.txt")
+	slip4s := readTestFile(t, "testdata/slip4s.txt")
+	mixed := slip2s + "\n" + slip4s
+	mnemonic := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip1s.txt")))
+	// slip4s needs just group 1 to recover, and slip2s all of its shares
+	recoverable := slip2s + "\n" + strings.SplitAfter(slip4s, "\n")[0]
+
+	headers := []string{
+		"Share set 1 of 2: identifier 28398 (iteration exponent 1, extendable) - 1 of 1 groups required\n" +
+			"  group 1: 3 members required, members 2, 3, 4 present\n",
+		"Share set 2 of 2: identifier 12600 (iteration exponent 1, extendable) - 1 of 2 groups required\n" +
+			"  group 1: 1 member required, member 1 present\n",
+	}
+	tests := []struct {
+		name    string
+		cmd     interface{ Run(*Context) error }
+		input   string
+		outputs []string
+	}{
+		{"sv", SlipValCmd{Partition: true}, mixed,
+			append(headers, "  group 2: 2 members required, members 1, 2, 3, 4 present\n",
+				"7 combinations produced the same BIP-39 mnemonic")},
+		{"sb", SlipBipCmd{Partition: true}, recoverable,
+			[]string{headers[0] + mnemonic + "\n\n" + headers[1] + mnemonic + "\n"}},
+		{"sl", SlipLabelCmd{Partition: true}, mixed, append(headers, "101 sympathy\n")},
+	}
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		ctx := Context{
+			reader: strings.NewReader(tc.input),
+			writer: &buf,
+		}
+		if err := tc.cmd.Run(&ctx); err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err.Error())
+			continue
+		}
+		for _, output := range tc.outputs {
+			if !strings.Contains(buf.String(), output) {
+				t.Errorf("%s: expected output containing %q, got:\n%s", tc.name, output, buf.String())
+			}
+		}
+	}
+
+	// Without partitioning, mixed sets fail
+	ctx := Context{reader: strings.NewReader(mixed), writer: io.Discard}
+	if err := (SlipValCmd{}).Run(&ctx); err == nil {
+		t.Errorf("sv of mixed share sets unexpectedly succeeded")
+	}
+}
+
+func TestSlipBip_Superset(t *testing.T) {
+	t.Parallel()
+
+	mnemonic := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip1s.txt")))
+	tampered := readTestFile(t, "testdata/slip6f.txt")
+	// A corrupted (bad checksum) copy of a slip7s share, and a share from a
+	// different backup
+	slip7s := strings.Split(strings.TrimSpace(readTestFile(t, "testdata/slip7s.txt")), "\n")
+	corrupted := strings.Fields(slip7s[1])
+	corrupted[10] = "academic"
+	foreign := strings.Split(readTestFile(t, "testdata/slip1s.txt"), "\n")[0]
+
+	tests := []struct {
+		slipfile string
+		input    string
+		want     string
+		report   []string
+		errstr   string
+	}{
+		{"slip7s.txt", "", mnemonic, []string{
+			"Used 1 of 3 shares: group 1 member 1 (input share 1)\n",
+			"Surplus shares: group 2 member 1 (input share 2), group 2 member 2 (input share 3)\n",
+			"Cross-checked 2 quorums - all produced the same secret\n",
+		}, ""},
+		{"slip8s.txt", "", mnemonic, []string{
+			"Used 1 of 4 shares: group 1 member 1 (input share 1)\n",
+			"Cross-checked 4 quorums",
+		}, ""},
+		// Large supersets are cross-checked by sampling quorums
+		{"slip10s.txt", "", mnemonic, []string{
+			"Used 8 of 16 shares: group 1 member 1 (input share 1), ",
+			"Surplus shares: group 1 member 9 (input share 9), ",
+			"Cross-checked a sample of 100 of the 12870 quorums - all produced the same secret\n",
+		}, ""},
+		// Invalid and foreign shares are ignored, if a quorum remains
+		{"slip7s.txt", strings.Join(append(slip7s, strings.Join(corrupted, " "), foreign), "\n"), mnemonic, []string{
+			"Used 1 of 5 shares: group 1 member 1 (input share 1)\n",
+			"Surplus shares: group 2 member 1 (input share 2), group 2 member 2 (input share 3)\n",
+			"Ignored shares:\n  input share 4 - invalid: ",
+			"\n  group 1 member 1 (input share 5) - from a different share set (identifier 28398)\n",
+			"Cross-checked 2 quorums - all produced the same secret\n",
+		}, ""},
+		{"slip7s.txt", strings.Join([]string{slip7s[1], strings.Join(corrupted, " ")}, "\n"), "", nil,
+			"no quorum of valid shares remains after ignoring:\n  input share 2 - invalid: "},
+		// A minimal set reports nothing
+		{"slip6f.txt", strings.Join(strings.Split(tampered, "\n")[:2], "\n"),
+			standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip2s.txt"))), nil, ""},
+		{"slip6f.txt", tampered, "", []string{"likely bad share: group 1 member 4 (input share 4)"},
+			"likely bad share(s): group 1 member 4 (input share 4)"},
+	}
+	for _, tc := range tests {
+		var buf, errbuf bytes.Buffer
+		ctx := Context{
+			reader:    strings.NewReader(tc.input),
+			writer:    &buf,
+			errWriter: &errbuf,
+		}
+		cmd := SlipBipCmd{}
+		if tc.input == "" {
+			cmd.Files = []string{"testdata/" + tc.slipfile}
+		}
+		err := cmd.Run(&ctx)
+		if tc.errstr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.errstr) {
+				t.Errorf("%s: expected error containing %q, got %v", tc.slipfile, tc.errstr, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.slipfile, err.Error())
+			continue
+		} else if got := strings.TrimSpace(buf.String()); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.slipfile, got, tc.want)
+		}
+		if tc.report == nil && tc.errstr == "" && errbuf.Len() > 0 {
+			t.Errorf("%s: unexpected report for a minimal set: %q", tc.slipfile, errbuf.String())
+		}
+		for _, r := range tc.report {
+			if !strings.Contains(errbuf.String(), r) {
+				t.Errorf("%s: expected report containing %q, got %q", tc.slipfile, r, errbuf.String())
+			}
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		policy string
+		want   string // canonical policy, if valid
+		errstr string
+	}{
+		{"2 of [family:2of3, lawyer:1of1, vault:3of5]", "2 of [family:2of3, lawyer:1of1, vault:3of5]", ""},
+		{"  1of[ 2 of 3 ,3of5 ] ", "1 of [2of3, 3of5]", ""},
+		{"family:2of3", "1 of [family:2of3]", ""},
+		{"2of3", "1 of [2of3]", ""},
+		{"2 of [a:1of5, b:1of1]", "", "group 1 (a:1of5): SLIP-39 does not allow a member threshold of 1 with more than one member"},
+		{"2 of [a:0of3, b:1of1]", "", "group 1 (a:0of3): member threshold must be at least 1"},
+		{"1 of [a:4of3]", "", "member threshold 4 is more than the member count 3"},
+		{"1 of [a:2of17]", "", "member count must be from 1 to 16"},
+		{"3 of [2of3, 1of1]", "", "group threshold must be from 1 to the number of groups (2), got 3"},
+		{"0 of [2of3, 1of1]", "", "group threshold must be from 1 to the number of groups (2), got 0"},
+		{"2 of [a:2of3, A:1of1]", "", `group name "A" is used more than once`},
+		{"2 of [a:2of3,, b:1of1]", "", `invalid policy group ""`},
+		{"2 of [3:2of3]", "", `invalid policy group "3:2of3"`},
+		{"2 of (a:2of3)", "", "invalid policy"},
+	}
+	for _, tc := range tests {
+		p, err := parsePolicy(tc.policy)
+		if tc.errstr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.errstr) {
+				t.Errorf("%q: expected error containing %q, got %v", tc.policy, tc.errstr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tc.policy, err.Error())
+			continue
+		}
+		if got := p.String(); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.policy, got, tc.want)
+		}
+	}
+
+	// parseGroups applies the same rules
+	for _, g := range []string{"0of3", "1of5", "4of3", "2of17"} {
+		if _, err := parseGroups([]string{g}); err == nil {
+			t.Errorf("parseGroups(%q) unexpectedly succeeded", g)
+		}
+	}
+}
+
+func TestBipSlip_Policy(t *testing.T) {
+	t.Parallel()
+
+	mnemonic := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip2s.txt")))
+	var buf bytes.Buffer
+	cmd := BipSlipCmd{
+		GroupThreshold: 1,
+		Policy:         "2 of [family:2of3, lawyer:1of1, vault:3of5]",
+		Seed:           []string{mnemonic},
+	}
+	if err := cmd.Run(&Context{writer: &buf}); err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range []string{
+		"# Group 1 (family), Share 1, 2of3, Threshold 2\n",
+		"# Group 2 (lawyer), 1of1, Threshold 2\n",
+		"# Group 3 (vault), Share 5, 3of5, Threshold 2\n",
+	} {
+		if !strings.Contains(buf.String(), header) {
+			t.Errorf("missing group header %q in output:\n%s", header, buf.String())
+		}
+	}
+
+	// The named output is still valid share input
+	var buf2 bytes.Buffer
+	ctx := Context{reader: strings.NewReader(buf.String()), writer: &buf2}
+	if err := (SlipValCmd{}).Run(&ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf2.String(), mnemonic) {
+		t.Errorf("sv of named shares did not produce %q:\n%s", mnemonic, buf2.String())
+	}
+
+	// Named groups are carried through to share titles
+	policy, err := parsePolicy(cmd.Policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shareGroups := slip39.ShareGroups{{"a", "b", "c"}, {"d"}, {"e", "f", "g", "h", "i"}}
+	displays := newShareDisplays("Cicero", policy, shareGroups)
+	if got, want := displays[3].title(), "Cicero, Group 2 (lawyer), 1of1, Threshold 2"; got != want {
+		t.Errorf("title: got %q, want %q", got, want)
+	}
+
+	cmd.GroupThreshold = 2
+	if err := cmd.Run(&Context{writer: io.Discard}); err == nil {
+		t.Errorf("--group-threshold with --policy unexpectedly succeeded")
+	}
+}
+
+func TestParsePolicyFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		data   string
+		want   string
+		errstr string
+	}{
+		{"yaml", readTestFile(t, "testdata/policy1.yaml"), "2 of [family:2of3, lawyer:1of1, vault:2of2]", ""},
+		{"json", readTestFile(t, "testdata/policy2.json"), "1 of [family:2of3]", ""},
+		{"default threshold", "groups: [{name: a, threshold: 1, members: [{name: x}]}]", "1 of [a:1of1]", ""},
+		{"empty", "", "", "policy is empty"},
+		{"unknown field", "groups: [{name: a, treshold: 1, members: [{name: x}]}]", "", "field treshold not found"},
+		{"no group name", "groups: [{threshold: 1, members: [{name: x}]}]", "", "group 1 has no name"},
+		{"no member name", "groups: [{name: a, threshold: 1, members: [{output: x.txt}]}]", "", `group "a" member 1 has no name`},
+		{"duplicate member", "groups: [{name: a, threshold: 2, members: [{name: x}, {name: X}]}]", "", `member name "X" is used more than once`},
+		{"duplicate output", "groups: [{name: a, threshold: 2, members: [{name: x, output: s.txt}, {name: y, output: s.txt}]}]",
+			"", `output "s.txt" is used more than once`},
+		{"1ofN", "groups: [{name: a, threshold: 1, members: [{name: x}, {name: y}]}]", "", "SLIP-39 does not allow a member threshold of 1"},
+		{"group threshold", "group_threshold: 0\ngroups: [{name: a, threshold: 1, members: [{name: x}]}]", "", "got 0"},
+	}
+	for _, tc := range tests {
+		p, err := parsePolicyFile([]byte(tc.data))
+		if tc.errstr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.errstr) {
+				t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.errstr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err.Error())
+			continue
+		}
+		if got := p.String(); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestBipSlip_PolicyFile(t *testing.T) {
+	t.Parallel()
+
+	mnemonic := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip2s.txt")))
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	cmd := BipSlipCmd{
+		GroupThreshold: 1,
+		PolicyFile:     "testdata/policy1.yaml",
+		OutputDir:      dir,
+		Seed:           []string{mnemonic},
+	}
+	if err := cmd.Run(&Context{writer: &buf}); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		"Share for Alice (Cicero, Group 1 (family), Share 1 (Alice), 2of3, Threshold 2) written to " +
+			filepath.Join(dir, "alice.txt") + "\n",
+		"Share for Bob (Cicero, Group 1 (family), Share 2 (Bob), 2of3, Threshold 2) encrypted to testdata/age1-recipient.txt: " +
+			filepath.Join(dir, "share-g1-m2.age") + "\n",
+		"Share for Dana (Cicero, Group 2 (lawyer), Dana, 1of1, Threshold 2) written to " +
+			filepath.Join(dir, "dana.txt") + "\n",
+		"# Cicero, Group 1 (family), Share 3 (Carol), 2of3, Threshold 2\n",
+		"# Cicero, Group 3 (vault), Share 2 (Frank), 2of2, Threshold 2\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output containing %q, got:\n%s", want, output)
+		}
+	}
+	for _, title := range []string{"Share 1 (Alice)", "Share 2 (Bob)", "Dana, 1of1"} {
+		if strings.Contains(output, "# Cicero, Group 1 (family), "+title) ||
+			strings.Contains(output, "# Cicero, Group 2 (lawyer), "+title) {
+			t.Errorf("share %q was written to a file, but also output:\n%s", title, output)
+		}
+	}
+
+	// Alice's, Bob's (encrypted), and Dana's shares recover the mnemonic
+	var buf2 bytes.Buffer
+	sb := SlipBipCmd{
+		Files: []string{
+			filepath.Join(dir, "alice.txt"),
+			filepath.Join(dir, "share-g1-m2.age"),
+			filepath.Join(dir, "dana.txt"),
+		},
+		Identities: []string{"testdata/age1-identity.txt"},
+	}
+	if err := sb.Run(&Context{writer: &buf2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf2.String()); got != mnemonic {
+		t.Errorf("sb got %q, want %q", got, mnemonic)
+	}
+
+	// Existing files are never overwritten
+	if err := cmd.Run(&Context{writer: io.Discard}); err == nil {
+		t.Errorf("bs --policy-file unexpectedly overwrote existing share files")
+	}
+}
+
+func TestSlipLabel_PolicyFile(t *testing.T) {
+	t.Parallel()
+
+	mnemonic := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip2s.txt")))
+	var buf bytes.Buffer
+	cmd := BipSlipCmd{GroupThreshold: 1, PolicyFile: "testdata/policy2.json", Seed: []string{mnemonic}}
+	if err := cmd.Run(&Context{writer: &buf, errWriter: io.Discard}); err != nil {
+		t.Fatal(err)
+	}
+	shares := buf.String()
+	mnemonics := regexp.MustCompile(`(?m)^[a-z ]+$`).FindAllString(shares, -1)
+
+	var buf2 bytes.Buffer
+	sl := SlipLabelCmd{Labels: "numeric", PolicyFile: "testdata/policy2.json"}
+	if err := sl.Run(&Context{reader: strings.NewReader(shares), writer: &buf2}); err != nil {
+		t.Fatal(err)
+	}
+	sheets := strings.Split(buf2.String(), "\n\n")
+	if len(sheets) != 3 {
+		t.Fatalf("expected 3 handoff sheets, got %d:\n%s", len(sheets), buf2.String())
+	}
+	for i, name := range []string{"Alice", "Bob", "Carol"} {
+		f, err := fingerprintShare(mnemonics[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := fmt.Sprintf("# Handoff sheet for %s\n# Cicero, family, Share %d (%s), 2of3\n# Fingerprint: %s\n%d01 ",
+			name, i+1, name, f, i+1)
+		if !strings.HasPrefix(sheets[i], header) {
+			t.Errorf("sheet %d: expected prefix %q, got:\n%s", i+1, header, sheets[i])
+		}
+	}
+
+	// The handoff sheets are still valid labelled words
+	var buf3 bytes.Buffer
+	if err := (LabelSlipCmd{}).Run(&Context{reader: strings.NewReader(buf2.String()), writer: &buf3}); err != nil {
+		t.Fatal(err)
+	}
+	want := regexp.MustCompile(`(?m)^#.*\n`).ReplaceAllString(shares, "")
+	if got := buf3.String(); got != want {
+		t.Errorf("ls of handoff sheets got %q, want %q", got, want)
+	}
+
+	// Shares from a different policy are rejected
+	sl.PolicyFile = "testdata/policy1.yaml"
+	err := sl.Run(&Context{reader: strings.NewReader(shares), writer: io.Discard})
+	if err == nil || !strings.Contains(err.Error(), "does not match the policy") {
+		t.Errorf("expected policy mismatch error, got %v", err)
+	}
+}
+
+// Test sl handoff sheets from a policy string carry its group names
+func TestSlipLabel_Policy(t *testing.T) {
+	t.Parallel()
+
+	policy := "2 of [family:2of3, lawyer:1of1]"
+	mnemonic := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip2s.txt")))
+	var buf bytes.Buffer
+	cmd := BipSlipCmd{GroupThreshold: 1, Policy: policy, Seed: []string{mnemonic}}
+	if err := cmd.Run(&Context{writer: &buf, errWriter: io.Discard}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf2 bytes.Buffer
+	sl := SlipLabelCmd{Labels: "gm", Policy: policy}
+	if err := sl.Run(&Context{reader: strings.NewReader(buf.String()), writer: &buf2}); err != nil {
+		t.Fatal(err)
+	}
+	sheets := strings.Split(buf2.String(), "\n\n")
+	if len(sheets) != 4 {
+		t.Fatalf("expected 4 handoff sheets, got %d:\n%s", len(sheets), buf2.String())
+	}
+	for i, title := range []string{
+		"Group 1 (family), Share 1, 2of3, Threshold 2\n",
+		"Group 1 (family), Share 2, 2of3, Threshold 2\n",
+		"Group 1 (family), Share 3, 2of3, Threshold 2\n",
+		"Group 2 (lawyer), 1of1, Threshold 2\n",
+	} {
+		if !strings.Contains(sheets[i], "\n# "+title) {
+			t.Errorf("sheet %d: expected title %q, got:\n%s", i+1, title, sheets[i])
+		}
+	}
+
+	// Shares from a different policy are rejected
+	sl.Policy = "2 of [family:2of3, lawyer:1of1, vault:3of5]"
+	err := sl.Run(&Context{reader: strings.NewReader(buf.String()), writer: io.Discard})
+	if err == nil || !strings.Contains(err.Error(), "does not match the policy") {
+		t.Errorf("expected policy mismatch error, got %v", err)
+	}
+}
+
+func TestPolicyAnalyze(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		policy       string
+		loss         float64
+		compromise   float64
+		recovery     float64
+		compromised  float64
+		minShares    int
+		combinations int64
+		critical     int
+	}{
+		{"2 of [2of3, 3of5]", 0.1, 0.1, 0.972 * 0.99144, 0.028 * 0.00856, 5, 30, 0},
+		{"1 of [2of2]", 0.1, 0.1, 0.81, 0.01, 2, 1, 2},
+		{"1 of [1of1, 2of3]", 0.5, 0.5, 1 - 0.5*0.5, 1 - 0.5*0.5, 1, 4, 0},
+		{"2 of [2of2, 1of1]", 0.1, 0, 0.81 * 0.9, 0, 3, 1, 3},
+		{"3 of [3of5, 3of5, 3of5, 3of5]", 0, 0, 1, 0, 9, 4000, 0},
+	}
+	for _, tc := range tests {
+		policy, err := parsePolicy(tc.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loss, err := memberProbabilities(policy, tc.loss, func(c custodian) *float64 { return c.loss }, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compromise, err := memberProbabilities(policy, tc.compromise, func(c custodian) *float64 { return c.compromise }, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := analyzePolicy(policy, loss, compromise)
+		if math.Abs(a.recovery-tc.recovery) > 1e-12 {
+			t.Errorf("%q: got recovery %g, want %g", tc.policy, a.recovery, tc.recovery)
+		}
+		if math.Abs(a.compromised-tc.compromised) > 1e-12 {
+			t.Errorf("%q: got compromise %g, want %g", tc.policy, a.compromised, tc.compromised)
+		}
+		if a.minShares != tc.minShares {
+			t.Errorf("%q: got minimum shares %d, want %d", tc.policy, a.minShares, tc.minShares)
+		}
+		if a.combinations.Int64() != tc.combinations {
+			t.Errorf("%q: got %s combinations, want %d", tc.policy, a.combinations, tc.combinations)
+		}
+		if len(a.critical) != tc.critical {
+			t.Errorf("%q: got %d single points of failure, want %d", tc.policy, len(a.critical), tc.critical)
+		}
+	}
+
+	// Per-member probabilities come from the policy file, then overrides
+	var buf bytes.Buffer
+	cmd := PolicyAnalyzeCmd{
+		GroupThreshold:   1,
+		PolicyFile:       "testdata/policy1.yaml",
+		Loss:             0,
+		Compromise:       0,
+		MemberLoss:       []string{"2.1=0.5"},
+		MemberCompromise: []string{"2.1=1"},
+	}
+	if err := cmd.Run(&Context{writer: &buf}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Policy: 2 of [family:2of3, lawyer:1of1, vault:2of2] (6 shares in 3 groups)",
+		"Minimum shares to recover: 3",
+		"group 2 (lawyer, 1of1) + group 3 (vault, 2of2): 3 shares, 1 combination\n",
+		"Combinations sv checks for a full set: 7\n",
+		"group 2 (lawyer, 1of1): recoverable 50%, compromised 100%",
+		"Recovery probability: 100%",
+		"Compromise probability (secret exposed): 0%",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("policy analyze output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	for _, o := range []string{"2.1", "4.1=0.1", "2.2=0.1", "1.1=1.5"} {
+		cmd := PolicyAnalyzeCmd{GroupThreshold: 1, Policy: "2 of [2of3, 1of1]", MemberLoss: []string{o}}
+		if err := cmd.Run(&Context{writer: io.Discard}); err == nil {
+			t.Errorf("--member-loss %q unexpectedly succeeded", o)
+		}
+	}
+}
+
+func TestPolicyExplain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cmd  PolicyExplainCmd
+		want []string
+	}{
+		{
+			PolicyExplainCmd{GroupThreshold: 2, Groups: []string{"2of3", "3of5"}},
+			[]string{
+				"Recovery plan: 2 of [2of3, 3of5]\n",
+				"needs shares from both of them:\n  - any 2 of the 3 shares from group 1, plus any 3 of the 5 shares from group 2\n",
+				"You hold share 2 of 3 in group 1. Recovering the secret needs your share and any 1 other share from group 1, plus any 3 of the 5 shares from group 2.\n",
+				"You hold share 5 of 5 in group 2. Recovering the secret needs your share and any 2 other shares from group 2, plus any 2 of the 3 shares from group 1.\n",
+			},
+		},
+		{
+			PolicyExplainCmd{GroupThreshold: 1, PolicyFile: "testdata/policy1.yaml"},
+			[]string{
+				"Recovery plan for Cicero: 2 of [family:2of3, lawyer:1of1, vault:2of2]\n",
+				"  - or the 1 share from group 2 (lawyer), plus both shares from group 3 (vault)\n",
+				"Dana - share 1 of 1 in group 2 (lawyer)\n  You hold share 1 of 1 in group 2 (lawyer). Recovering the secret needs your share, plus any 2 of the 3 shares from group 1 (family) or both shares from group 3 (vault).\n",
+				"Erin - share 1 of 2 in group 3 (vault)\n  You hold share 1 of 2 in group 3 (vault). Recovering the secret needs your share and the other share from group 3 (vault)",
+			},
+		},
+		{
+			PolicyExplainCmd{GroupThreshold: 1, Groups: []string{"1of1"}},
+			[]string{
+				"Your share alone can recover the secret",
+			},
+		},
+		{
+			PolicyExplainCmd{Files: []string{"testdata/slip2s.txt"}},
+			[]string{
+				"Recovery plan for shares with identifier 28398: 1 of 1 groups required\n",
+				"  - any 3 shares from group 1\n",
+				"You hold share 2 in group 1. Recovering the secret needs your share and any 2 other shares from group 1.\n",
+			},
+		},
+	}
+	for i, tc := range tests {
+		var buf bytes.Buffer
+		if err := tc.cmd.Run(&Context{writer: &buf}); err != nil {
+			t.Fatalf("test %d: %s", i+1, err.Error())
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("test %d: output missing %q:\n%s", i+1, want, buf.String())
+			}
+		}
+	}
+
+	// Explaining shares must not reveal them
+	var buf bytes.Buffer
+	cmd := PolicyExplainCmd{Files: []string{"testdata/slip2s.txt"}}
+	if err := cmd.Run(&Context{writer: &buf}); err != nil {
+		t.Fatal(err)
+	}
+	for _, mnemonic := range strings.Split(readTestFile(t, "testdata/slip2s.txt"), "\n") {
+		words := strings.Fields(mnemonic)
+		if len(words) > 4 && strings.Contains(buf.String(), strings.Join(words[4:8], " ")) {
+			t.Errorf("policy explain output reveals share words %q", strings.Join(words[4:8], " "))
+		}
+	}
+
+	// A share whose group index is beyond its group count is rejected
+	cmd = PolicyExplainCmd{Files: []string{"testdata/slip9f.txt"}}
+	if err := cmd.Run(&Context{writer: io.Discard}); err == nil || !strings.Contains(err.Error(), "in group 6, but its set only has 1 group") {
+		t.Errorf("policy explain with an out of range group index: expected error, got %v", err)
+	}
+
+	cmd = PolicyExplainCmd{GroupThreshold: 1, Groups: []string{"2of3"}, Files: []string{"testdata/slip2s.txt"}}
+	if err := cmd.Run(&Context{writer: io.Discard}); err == nil {
+		t.Error("policy explain with both a policy and shares unexpectedly succeeded")
+	}
+}
+
+func TestShareFingerprint(t *testing.T) {
+	t.Parallel()
+
+	want := []string{
+		"28398 G1M1 observe zero garlic region",
+		"28398 G1M2 mystery grain upset oyster",
+		"28398 G1M3 hospital uncle must wedding",
+	}
+	// Fingerprints don't depend on case or whitespace
+	for _, file := range []string{"testdata/slip1s.txt", "testdata/slip1su.txt"} {
+		mnemonics := strings.Split(strings.TrimSpace(readTestFile(t, file)), "\n")
+		for i, mnemonic := range mnemonics {
+			f, err := fingerprintShare(mnemonic)
+			if err != nil {
+				t.Fatalf("%s: %s", file, err.Error())
+			}
+			if f.String() != want[i] {
+				t.Errorf("%s share %d: got fingerprint %q, want %q", file, i+1, f, want[i])
+			}
+		}
+	}
+
+	tests := []struct {
+		fingerprint string
+		want        string
+		errstr      string
+	}{
+		{"28398 G1M2 mystery grain upset oyster", "28398 G1M2 mystery grain upset oyster", ""},
+		{"  28398-g1m2: Mystery, Grain, Upset, Oyster ", "28398 G1M2 mystery grain upset oyster", ""},
+		{"28398 G1 M2 mystery-grain-upset-oyster", "28398 G1M2 mystery grain upset oyster", ""},
+		{"28398 G1M2 mystery grain upset", "", "expected 4 words, got 3"},
+		{"G1M2 mystery grain upset oyster", "", "invalid fingerprint"},
+		{"28398 mystery grain upset oyster", "", "invalid fingerprint"},
+	}
+	for _, tc := range tests {
+		f, err := parseFingerprint(tc.fingerprint)
+		if tc.errstr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.errstr) {
+				t.Errorf("%q: expected error containing %q, got %v", tc.fingerprint, tc.errstr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tc.fingerprint, err.Error())
+			continue
+		}
+		if f.String() != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.fingerprint, f, tc.want)
+		}
+	}
+}
+
+func TestVerifyFingerprint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fingerprint string
+		want        string
+		errstr      string
+	}{
+		{"28398 g1m2 mystery grain upset oyster", "✔ Share matches fingerprint 28398 G1M2 mystery grain upset oyster", ""},
+		{"28398 G1M1 observe zero garlic region", "group 1 member 2, not group 1 member 1", "does not match"},
+		{"28399 G1M2 mystery grain upset oyster", "identifier 28398, not 28399", "does not match"},
+		{"28398 G1M2 mystery grain upset zoo", "words do not match", "does not match"},
+		{"28398 mystery grain upset oyster", "", "invalid fingerprint"},
+	}
+	share := strings.Split(readTestFile(t, "testdata/slip1s.txt"), "\n")[1]
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		cmd := VerifyFingerprintCmd{Fingerprint: tc.fingerprint, Share: []string{share}}
+		err := cmd.Run(&Context{writer: &buf})
+		if tc.errstr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.errstr) {
+				t.Errorf("%q: expected error containing %q, got %v", tc.fingerprint, tc.errstr, err)
+			}
+		} else if err != nil {
+			t.Errorf("%q: unexpected error: %s", tc.fingerprint, err.Error())
+		}
+		if !strings.Contains(buf.String(), tc.want) {
+			t.Errorf("%q: output missing %q:\n%s", tc.fingerprint, tc.want, buf.String())
+		}
+		if strings.Contains(buf.String(), share[:40]) {
+			t.Errorf("%q: output reveals the share:\n%s", tc.fingerprint, buf.String())
+		}
+	}
+
+	// Exactly one share is verified
+	cmd := VerifyFingerprintCmd{Fingerprint: tests[0].fingerprint, Files: []string{"testdata/slip1s.txt"}}
+	if err := cmd.Run(&Context{writer: io.Discard}); err == nil {
+		t.Error("verify-fingerprint with 3 shares unexpectedly succeeded")
+	}
+}
+
+func TestBipSlip_Fingerprints(t *testing.T) {
+	t.Parallel()
+
+	mnemonic := standardiseMnemonicBytes([]byte(readTestFile(t, "testdata/bip2s.txt")))
+	var buf, errbuf bytes.Buffer
+	cmd := BipSlipCmd{GroupThreshold: 1, Groups: []string{"2of3"}, Seed: []string{mnemonic}}
+	if err := cmd.Run(&Context{writer: &buf, errWriter: &errbuf}); err != nil {
+		t.Fatal(err)
+	}
+	mnemonics := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	// bs and sl list the same fingerprints on stderr, without the shares
+	var slbuf, slerrbuf bytes.Buffer
+	sl := SlipLabelCmd{Labels: "numeric"}
+	if err := sl.Run(&Context{reader: strings.NewReader(buf.String()), writer: &slbuf, errWriter: &slerrbuf}); err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range mnemonics {
+		f, err := fingerprintShare(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf("  %s  (Share %d, 2of3)\n", f, i+1)
+		if !strings.Contains(errbuf.String(), want) {
+			t.Errorf("bs stderr missing %q:\n%s", want, errbuf.String())
+		}
+		if !strings.Contains(slerrbuf.String(), fmt.Sprintf("  %s\n", f)) {
+			t.Errorf("sl stderr missing %q:\n%s", f, slerrbuf.String())
+		}
+		if strings.Contains(errbuf.String()+slerrbuf.String(), m) {
+			t.Errorf("fingerprint output reveals share %d", i+1)
+		}
+	}
+}
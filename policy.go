@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gavincarr/go-slip39"
+)
+
+var (
+	rePolicy      = regexp.MustCompile(`^(\d+)\s*of\s*\[(.*)\]$`)
+	rePolicyGroup = regexp.MustCompile(`^(?:([A-Za-z][\w-]*)\s*:\s*)?(\d+)\s*of\s*(\d+)$`)
+)
+
+// policyExample is an example policy, for error messages
+const policyExample = "2 of [family:2of3, lawyer:1of1, vault:3of5]"
+
+// custodian is a named member of a policyGroup, from a policy file, with an
+// optional destination for their share
+type custodian struct {
+	name       string
+	output     string   // file to write the share to
+	encryptTo  string   // recipient to encrypt the share to
+	loss       *float64 // probability the share is lost, if given
+	compromise *float64 // probability the share is compromised, if given
+}
+
+// policyGroup is a (possibly named) group of members in a sharePolicy
+type policyGroup struct {
+	name       string
+	threshold  int
+	count      int
+	custodians []custodian // from a policy file, one per member
+}
+
+// String returns g in policy syntax e.g. "family:2of3"
+func (g policyGroup) String() string {
+	if g.name == "" {
+		return fmt.Sprintf("%dof%d", g.threshold, g.count)
+	}
+	return fmt.Sprintf("%s:%dof%d", g.name, g.threshold, g.count)
+}
+
+// sharePolicy is a SLIP39 sharing policy: the groups of members to split a
+// secret between, and the number of groups required to recover it
+type sharePolicy struct {
+	wallet         string // from a policy file
+	groupThreshold int
+	groups         []policyGroup
+}
+
+// newSharePolicy returns an (unnamed) sharePolicy for groupThreshold and groups
+func newSharePolicy(groupThreshold int, groups []slip39.MemberGroupParameters) *sharePolicy {
+	p := &sharePolicy{groupThreshold: groupThreshold}
+	for _, g := range groups {
+		p.groups = append(p.groups, policyGroup{
+			threshold: g.MemberThreshold,
+			count:     g.MemberCount,
+		})
+	}
+	return p
+}
+
+// parsePolicy parses a policy like "2 of [family:2of3, lawyer:1of1, vault:3of5]",
+// where group names are optional. A single group may be given on its own
+// e.g. "family:2of3".
+func parsePolicy(policy string) (*sharePolicy, error) {
+	policy = strings.TrimSpace(policy)
+	p := &sharePolicy{groupThreshold: 1}
+	groupstr := policy
+	if matches := rePolicy.FindStringSubmatch(policy); matches != nil {
+		p.groupThreshold, _ = strconv.Atoi(matches[1])
+		groupstr = matches[2]
+	} else if !rePolicyGroup.MatchString(policy) {
+		return nil, fmt.Errorf("invalid policy %q: expected e.g. %q", policy, policyExample)
+	}
+
+	names := map[string]bool{}
+	for _, gs := range strings.Split(groupstr, ",") {
+		gs = strings.TrimSpace(gs)
+		matches := rePolicyGroup.FindStringSubmatch(gs)
+		if matches == nil {
+			return nil, fmt.Errorf("invalid policy group %q: expected \"[name:]MofN\" e.g. \"family:2of3\"", gs)
+		}
+		g := policyGroup{name: matches[1]}
+		g.threshold, _ = strconv.Atoi(matches[2])
+		g.count, _ = strconv.Atoi(matches[3])
+		if g.name != "" {
+			if names[strings.ToLower(g.name)] {
+				return nil, fmt.Errorf("invalid policy: group name %q is used more than once", g.name)
+			}
+			names[strings.ToLower(g.name)] = true
+		}
+		p.groups = append(p.groups, g)
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// resolveSharePolicy returns the sharing policy from a policy file or policy
+// string, or from groupThreshold and "MofN" groups, whichever is given
+func resolveSharePolicy(groupThreshold int, groups []string, policy, policyFile string) (*sharePolicy, error) {
+	if policy != "" || policyFile != "" {
+		if groupThreshold != 1 {
+			return nil, errors.New("--group-threshold cannot be used with --policy or --policy-file (the policy includes the group threshold)")
+		}
+		if policyFile != "" {
+			return loadPolicyFile(policyFile)
+		}
+		return parsePolicy(policy)
+	}
+	memberGroups, err := parseGroups(groups)
+	if err != nil {
+		return nil, err
+	}
+	p := newSharePolicy(groupThreshold, memberGroups)
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// validateMemberGroup checks a group's member threshold and count against
+// the SLIP-39 spec
+func validateMemberGroup(threshold, count int) error {
+	switch {
+	case count < 1 || count > GroupLimit:
+		return fmt.Errorf("member count must be from 1 to %d (SLIP-39 allows at most %d shares per group)",
+			GroupLimit, GroupLimit)
+	case threshold < 1:
+		return errors.New("member threshold must be at least 1")
+	case threshold > count:
+		return fmt.Errorf("member threshold %d is more than the member count %d", threshold, count)
+	case threshold == 1 && count > 1:
+		return errors.New("SLIP-39 does not allow a member threshold of 1 with more than one member (every share would be identical) - use 1of1 instead")
+	}
+	return nil
+}
+
+// validate checks p against the SLIP-39 spec
+func (p *sharePolicy) validate() error {
+	if len(p.groups) < 1 || len(p.groups) > GroupLimit {
+		return fmt.Errorf("invalid policy: must have from 1 to %d groups (SLIP-39 allows at most %d), got %d",
+			GroupLimit, GroupLimit, len(p.groups))
+	}
+	for i, g := range p.groups {
+		if err := validateMemberGroup(g.threshold, g.count); err != nil {
+			return fmt.Errorf("invalid policy group %d (%s): %w", i+1, g, err)
+		}
+	}
+	if p.groupThreshold < 1 || p.groupThreshold > len(p.groups) {
+		return fmt.Errorf("invalid policy: group threshold must be from 1 to the number of groups (%d), got %d",
+			len(p.groups), p.groupThreshold)
+	}
+	return nil
+}
+
+// memberGroups returns the slip39 group parameters for p
+func (p *sharePolicy) memberGroups() []slip39.MemberGroupParameters {
+	groups := make([]slip39.MemberGroupParameters, len(p.groups))
+	for i, g := range p.groups {
+		groups[i] = slip39.MemberGroupParameters{
+			MemberThreshold: g.threshold,
+			MemberCount:     g.count,
+		}
+	}
+	return groups
+}
+
+// named returns true if any of the groups or members in p are named
+func (p *sharePolicy) named() bool {
+	for _, g := range p.groups {
+		if g.name != "" || len(g.custodians) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns p in policy syntax e.g. "2 of [family:2of3, vault:3of5]"
+func (p *sharePolicy) String() string {
+	groups := make([]string, len(p.groups))
+	for i, g := range p.groups {
+		groups[i] = g.String()
+	}
+	return fmt.Sprintf("%d of [%s]", p.groupThreshold, strings.Join(groups, ", "))
+}
+
+// writeAnnotatedShares writes the shares in displays to w one per line, like
+// slip39.ShareGroups.String, each preceded by a comment line with its title
+// (which share inputs ignore)
+func writeAnnotatedShares(w io.Writer, displays []shareDisplay) {
+	for _, sd := range displays {
+		fmt.Fprintf(w, "# %s\n%s\n", sd.title(), sd.mnemonic)
+	}
+}
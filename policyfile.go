@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/gavincarr/go-slip39"
+	"gopkg.in/yaml.v3"
+)
+
+// policyFile is the YAML (or JSON) policy file format e.g.
+//
+//	wallet: Cicero
+//	group_threshold: 2
+//	groups:
+//	  - name: family
+//	    threshold: 2
+//	    members:
+//	      - name: Alice
+//	        output: alice.txt
+//	      - name: Bob
+//	        encrypt_to: age1...
+//	      - name: Carol
+//	  - name: lawyer
+//	    threshold: 1
+//	    members:
+//	      - name: Dana
+type policyFile struct {
+	Wallet         string            `yaml:"wallet"`
+	GroupThreshold *int              `yaml:"group_threshold"`
+	Groups         []policyFileGroup `yaml:"groups"`
+}
+
+type policyFileGroup struct {
+	Name      string             `yaml:"name"`
+	Threshold int                `yaml:"threshold"`
+	Members   []policyFileMember `yaml:"members"`
+}
+
+type policyFileMember struct {
+	Name       string   `yaml:"name"`
+	Output     string   `yaml:"output"`
+	EncryptTo  string   `yaml:"encrypt_to"`
+	Loss       *float64 `yaml:"loss"`       // see policy analyze
+	Compromise *float64 `yaml:"compromise"` // see policy analyze
+}
+
+// loadPolicyFile reads and validates the YAML or JSON policy in filename
+func loadPolicyFile(filename string) (*sharePolicy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy file: %w", err)
+	}
+	p, err := parsePolicyFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("policy file %q: %w", filename, err)
+	}
+	return p, nil
+}
+
+// parsePolicyFile parses and validates a YAML or JSON policy. Group and
+// member names are required, and must be unique.
+func parsePolicyFile(data []byte) (*sharePolicy, error) {
+	var pf policyFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&pf); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("policy is empty")
+		}
+		return nil, err
+	}
+
+	p := &sharePolicy{wallet: pf.Wallet, groupThreshold: 1}
+	if pf.GroupThreshold != nil {
+		p.groupThreshold = *pf.GroupThreshold
+	}
+	groupNames := map[string]bool{}
+	memberNames := map[string]bool{}
+	outputs := map[string]bool{}
+	for i, pg := range pf.Groups {
+		if pg.Name == "" {
+			return nil, fmt.Errorf("group %d has no name", i+1)
+		}
+		if groupNames[strings.ToLower(pg.Name)] {
+			return nil, fmt.Errorf("group name %q is used more than once", pg.Name)
+		}
+		groupNames[strings.ToLower(pg.Name)] = true
+
+		g := policyGroup{name: pg.Name, threshold: pg.Threshold, count: len(pg.Members)}
+		for j, pm := range pg.Members {
+			if pm.Name == "" {
+				return nil, fmt.Errorf("group %q member %d has no name", pg.Name, j+1)
+			}
+			if memberNames[strings.ToLower(pm.Name)] {
+				return nil, fmt.Errorf("member name %q is used more than once", pm.Name)
+			}
+			memberNames[strings.ToLower(pm.Name)] = true
+			if pm.Output != "" {
+				if outputs[pm.Output] {
+					return nil, fmt.Errorf("output %q is used more than once", pm.Output)
+				}
+				outputs[pm.Output] = true
+			}
+			for _, prob := range []*float64{pm.Loss, pm.Compromise} {
+				if prob != nil && (*prob < 0 || *prob > 1) {
+					return nil, fmt.Errorf("member %q: probabilities must be from 0 to 1, got %g", pm.Name, *prob)
+				}
+			}
+			g.custodians = append(g.custodians, custodian{
+				name:       pm.Name,
+				output:     pm.Output,
+				encryptTo:  pm.EncryptTo,
+				loss:       pm.Loss,
+				compromise: pm.Compromise,
+			})
+		}
+		p.groups = append(p.groups, g)
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// shareDisplayFor returns the shareDisplay for share s (with mnemonic) under
+// policy p, or an error if s was not generated using p
+func (p *sharePolicy) shareDisplayFor(s slip39.Share, mnemonic string) (shareDisplay, error) {
+	if s.GroupThreshold != p.groupThreshold || s.GroupCount != len(p.groups) ||
+		s.GroupIndex >= len(p.groups) ||
+		s.MemberThreshold != p.groups[s.GroupIndex].threshold ||
+		s.MemberIndex >= p.groups[s.GroupIndex].count {
+		return shareDisplay{}, fmt.Errorf(
+			"share (group %d member %d, %d of %d groups, member threshold %d) does not match the policy %s",
+			s.GroupIndex+1, s.MemberIndex+1, s.GroupThreshold, s.GroupCount, s.MemberThreshold, p)
+	}
+	g := p.groups[s.GroupIndex]
+	sd := shareDisplay{
+		wallet:          p.wallet,
+		group:           s.GroupIndex + 1,
+		groupName:       g.name,
+		groupCount:      len(p.groups),
+		groupThreshold:  p.groupThreshold,
+		member:          s.MemberIndex + 1,
+		memberCount:     g.count,
+		memberThreshold: g.threshold,
+		mnemonic:        mnemonic,
+	}
+	if s.MemberIndex < len(g.custodians) {
+		sd.custodian = g.custodians[s.MemberIndex].name
+	}
+	return sd, nil
+}
+
+// writeShareDestinations writes each share in displays whose custodian has
+// an output or encrypt_to destination in policy to its file (relative to
+// dir), and returns the remaining shares. Existing files are never
+// overwritten.
+func writeShareDestinations(
+	ctx *Context,
+	dir string,
+	policy *sharePolicy,
+	displays []shareDisplay,
+) ([]shareDisplay, error) {
+	remaining := []shareDisplay{}
+	for _, sd := range displays {
+		var c custodian
+		if custodians := policy.groups[sd.group-1].custodians; sd.member <= len(custodians) {
+			c = custodians[sd.member-1]
+		}
+		if c.output == "" && c.encryptTo == "" {
+			remaining = append(remaining, sd)
+			continue
+		}
+
+		plaintext := []byte(fmt.Sprintf("# %s\n%s\n", sd.title(), sd.mnemonic))
+		var data []byte
+		var err error
+		filename := c.output
+		if c.encryptTo != "" {
+			r, rerr := parseShareRecipient(c.encryptTo)
+			if rerr != nil {
+				wipeBytes(plaintext)
+				return nil, fmt.Errorf("custodian %q: %w", c.name, rerr)
+			}
+			if filename == "" {
+				filename = encryptedShareFilename(sd, r)
+			}
+			var buf bytes.Buffer
+			err = r.encrypt(&buf, plaintext)
+			wipeBytes(plaintext)
+			if err != nil {
+				return nil, fmt.Errorf("encrypting share for %q: %w", c.name, err)
+			}
+			data = buf.Bytes()
+		} else {
+			data = plaintext
+		}
+		if !filepath.IsAbs(filename) {
+			filename = filepath.Join(dir, filename)
+		}
+		err = writeExclusiveFile(filename, data)
+		wipeBytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("writing share for %q: %w", c.name, err)
+		}
+		verb := "written to"
+		if c.encryptTo != "" {
+			verb = fmt.Sprintf("encrypted to %s:", c.encryptTo)
+		}
+		fmt.Fprintf(ctx.writer, "%s Share for %s (%s) %s %s\n",
+			color.GreenString(tickGlyph), c.name, sd.title(), verb, filename)
+	}
+	return remaining, nil
+}
+
+// writeExclusiveFile writes data to a new file filename, readable only by
+// the owner, failing if filename already exists
+func writeExclusiveFile(filename string, data []byte) error {
+	fh, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = fh.Write(data)
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(filename)
+	}
+	return err
+}
+
+// writeHandoffSheets writes the labelled words for each share in shareGroups
+// (as formatted in words) to w, headed by the share's custodian and title
+// from policy, and separated by blank lines
+func writeHandoffSheets(w io.Writer, policy *sharePolicy, shareGroups slip39.ShareGroups, words string) error {
+	lines := strings.Split(strings.TrimRight(words, "\n"), "\n")
+	i := 0
+	for _, shares := range shareGroups {
+		for _, mnemonic := range shares {
+			s, err := slip39.ParseShare(mnemonic)
+			if err != nil {
+				return err
+			}
+			sd, err := policy.shareDisplayFor(s, mnemonic)
+			if err != nil {
+				return err
+			}
+			n := len(strings.Fields(mnemonic))
+			if i+n > len(lines) {
+				return errors.New("labelled words do not match the shares")
+			}
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			holder := sd.custodian
+			if holder == "" {
+				holder = "unnamed custodian"
+			}
+			f, err := fingerprintShare(mnemonic)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "# Handoff sheet for %s\n# %s\n# Fingerprint: %s\n", holder, sd.title(), f)
+			for _, line := range lines[i : i+n] {
+				fmt.Fprintln(w, line)
+			}
+			i += n
+		}
+	}
+	return nil
+}
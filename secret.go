@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"runtime"
+
+	"github.com/fatih/color"
+)
+
+// Initial capacity for buffers used to read secrets
+const secretReadSize = 4096
+
+// secretBuffer holds secret material (mnemonic, entropy, or passphrase
+// bytes), locked into memory where the platform allows so that it isn't
+// swapped to disk. Wipe should be called as soon as the secret is no longer
+// needed.
+//
+// This protects only the raw input: readSecretString and readSecretFileString
+// copy it straight into an ordinary Go string, because tokenizing and parsing,
+// go-bip39, and go-slip39 all work on strings, and passphrases are plain
+// string flags. Those strings can't be locked or wiped, and may remain in
+// memory (or be swapped out) until the process exits.
+type secretBuffer struct {
+	b      []byte
+	locked bool
+}
+
+// newSecretBuffer returns a secretBuffer that takes ownership of b
+func newSecretBuffer(b []byte) *secretBuffer {
+	return &secretBuffer{b: b, locked: lockMemory(b) == nil}
+}
+
+// Bytes returns the secret bytes, which are only valid until Wipe is called
+func (s *secretBuffer) Bytes() []byte {
+	return s.b
+}
+
+// Wipe overwrites the secret with zeroes, and unlocks its memory
+func (s *secretBuffer) Wipe() {
+	wipeBytes(s.b)
+	if s.locked {
+		unlockMemory(s.b)
+		s.locked = false
+	}
+	s.b = nil
+}
+
+// readSecret reads all of r into a secretBuffer. Unlike io.ReadAll, it wipes
+// the buffers it outgrows rather than leaving copies of the secret behind.
+func readSecret(r io.Reader) (*secretBuffer, error) {
+	buf := make([]byte, 0, secretReadSize)
+	for {
+		if len(buf) == cap(buf) {
+			grown := make([]byte, len(buf), 2*cap(buf))
+			copy(grown, buf)
+			wipeBytes(buf)
+			buf = grown
+		}
+		n, err := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			return newSecretBuffer(buf), nil
+		}
+		if err != nil {
+			wipeBytes(buf)
+			return nil, err
+		}
+	}
+}
+
+// readSecretFile reads the contents of filename into a secretBuffer
+func readSecretFile(filename string) (*secretBuffer, error) {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return readSecret(fh)
+}
+
+// readSecretString reads all of r and returns it as a string, wiping the
+// bytes read (the returned string is not protected - see secretBuffer)
+func readSecretString(r io.Reader) (string, error) {
+	sb, err := readSecret(r)
+	if err != nil {
+		return "", err
+	}
+	defer sb.Wipe()
+	return string(sb.Bytes()), nil
+}
+
+// readSecretFileString reads filename and returns its contents as a string,
+// wiping the bytes read (the returned string is not protected - see
+// secretBuffer)
+func readSecretFileString(filename string) (string, error) {
+	sb, err := readSecretFile(filename)
+	if err != nil {
+		return "", err
+	}
+	defer sb.Wipe()
+	return string(sb.Bytes()), nil
+}
+
+// wipeBytes overwrites each of bufs with zeroes
+func wipeBytes(bufs ...[]byte) {
+	for _, b := range bufs {
+		clear(b)
+		runtime.KeepAlive(b)
+	}
+}
+
+// wipeBigInt overwrites the internal words of n with zeroes, and sets n to 0
+func wipeBigInt(n *big.Int) {
+	if n == nil {
+		return
+	}
+	words := n.Bits()
+	clear(words[:cap(words)])
+	runtime.KeepAlive(words)
+	n.SetInt64(0)
+}
+
+// writeSecretWords writes words to w as a single space-separated line, via a
+// wiped byte buffer rather than a joined string
+func writeSecretWords(w io.Writer, words []string) error {
+	size := 0
+	for _, word := range words {
+		size += len(word) + 1
+	}
+	sb := newSecretBuffer(make([]byte, 0, size))
+	defer sb.Wipe()
+	for i, word := range words {
+		if i > 0 {
+			sb.b = append(sb.b, ' ')
+		}
+		sb.b = append(sb.b, word...)
+	}
+	sb.b = append(sb.b, '\n')
+	_, err := w.Write(sb.b)
+	return err
+}
+
+// bip39ChecksumValid returns true if words form a BIP39 mnemonic with a valid
+// checksum. Unlike bip39.IsMnemonicValid, it doesn't require the mnemonic to
+// be joined into a string, and wipes its intermediate values.
+func bip39ChecksumValid(words []string) bool {
+	size := len(words)
+	if size < 12 || size > 24 || size%3 != 0 {
+		return false
+	}
+	n, err := bip39Entropy(words)
+	if err != nil {
+		return false
+	}
+	defer wipeBigInt(n)
+
+	checksumBits := size / 3
+	checksum := 0
+	for b := checksumBits - 1; b >= 0; b-- {
+		checksum = checksum<<1 | int(n.Bit(b))
+	}
+	n.Rsh(n, uint(checksumBits))
+	entropy := n.FillBytes(make([]byte, (size*11-checksumBits)/8))
+	hash := sha256.Sum256(entropy)
+	valid := int(hash[0])>>(8-checksumBits) == checksum
+	wipeBytes(entropy, hash[:])
+	return valid
+}
+
+// bip39EntropyFromWords returns the entropy for the BIP39 mnemonic words,
+// which the caller should wipe after use
+func bip39EntropyFromWords(words []string) ([]byte, error) {
+	if size := len(words); size < 12 || size > 24 || size%3 != 0 {
+		return nil, fmt.Errorf("invalid BIP-39 mnemonic length %d words (must be 12, 15, 18, 21, or 24)",
+			size)
+	}
+	if !bip39ChecksumValid(words) {
+		return nil, errors.New("invalid BIP-39 mnemonic checksum")
+	}
+	n, err := bip39Entropy(words)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBigInt(n)
+	checksumBits := len(words) / 3
+	n.Rsh(n, uint(checksumBits))
+	return n.FillBytes(make([]byte, (len(words)*11-checksumBits)/8)), nil
+}
+
+// hardenProcess applies process-wide protections for handling secrets,
+// currently disabling core dumps, and warns on failure
+func hardenProcess(w io.Writer) {
+	if err := disableCoreDumps(); err != nil {
+		fmt.Fprintf(w, "%s unable to disable core dumps: %s\n",
+			color.YellowString("Warning:"), err.Error())
+	}
+}
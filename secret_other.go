@@ -0,0 +1,23 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// lockMemory is a no-op on platforms without mlock
+func lockMemory(b []byte) error {
+	return nil
+}
+
+// unlockMemory is a no-op on platforms without mlock
+func unlockMemory(b []byte) {}
+
+// disableCoreDumps is a no-op on platforms without core dump limits
+func disableCoreDumps() error {
+	return nil
+}
+
+// coreDumpLimit is unsupported on platforms without core dump limits
+func coreDumpLimit() (uint64, error) {
+	return 0, errors.New("core dump limits are not supported on this platform")
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// lockMemory locks b into memory, preventing it from being swapped to disk
+func lockMemory(b []byte) error {
+	if cap(b) == 0 {
+		return nil
+	}
+	return unix.Mlock(b[:cap(b)])
+}
+
+// unlockMemory unlocks memory locked by lockMemory
+func unlockMemory(b []byte) {
+	if cap(b) > 0 {
+		unix.Munlock(b[:cap(b)])
+	}
+}
+
+// disableCoreDumps sets the core dump size limit to zero, so that secrets
+// can't end up in a core file if seedkit crashes
+func disableCoreDumps() error {
+	return unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{Cur: 0, Max: 0})
+}
+
+// coreDumpLimit returns the current (soft) core dump size limit
+func coreDumpLimit() (uint64, error) {
+	var rlimit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_CORE, &rlimit); err != nil {
+		return 0, err
+	}
+	return uint64(rlimit.Cur), nil
+}
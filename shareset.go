@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/gavincarr/go-slip39"
+)
+
+// shareKey is the position a share claims within a share set
+type shareKey struct {
+	identifier int
+	group      int
+	member     int
+}
+
+// removeDuplicateShares returns mnemonics with any repeated shares removed,
+// writing a warning about each to w, along with the (1-based) input position
+// of each share returned
+func removeDuplicateShares(w io.Writer, mnemonics []string) ([]string, []int) {
+	deduped := make([]string, 0, len(mnemonics))
+	positions := make([]int, 0, len(mnemonics))
+	seen := map[string]int{}
+	for i, mnemonic := range mnemonics {
+		normalised := strings.Join(strings.Fields(mnemonic), " ")
+		if first, ok := seen[normalised]; ok {
+			fmt.Fprintf(w, "%s ignoring share %d - it is a duplicate of share %d\n",
+				color.YellowString("Warning:"), i+1, first+1)
+			continue
+		}
+		seen[normalised] = i
+		deduped = append(deduped, mnemonic)
+		positions = append(positions, i+1)
+	}
+	return deduped, positions
+}
+
+// dedupeShares returns mnemonics with any repeated shares removed, writing a
+// warning about each to w, along with the input position of each share
+// returned. It returns an error if two different shares claim the same
+// identifier, group, and member index, which means shares from two backups
+// have been mixed together, or tampered with, or if a share's group index is
+// beyond its group count. Shares that fail to parse are passed through for
+// the caller to report.
+func dedupeShares(w io.Writer, mnemonics []string) ([]string, []int, error) {
+	deduped, positions := removeDuplicateShares(w, mnemonics)
+	claimed := map[shareKey]int{}
+	for i, mnemonic := range deduped {
+		s, err := slip39.ParseShare(mnemonic)
+		if err != nil {
+			continue
+		}
+		// ParseShare doesn't check the group index against the group count
+		// (and go-slip39 panics on such shares)
+		if s.GroupIndex >= s.GroupCount {
+			return nil, nil, fmt.Errorf("invalid share %d: it is in group %d, but its set only has %d %s",
+				positions[i], s.GroupIndex+1, s.GroupCount, plural(s.GroupCount, "group"))
+		}
+		key := shareKey{
+			identifier: s.Identifier,
+			group:      s.GroupIndex,
+			member:     s.MemberIndex,
+		}
+		if first, ok := claimed[key]; ok {
+			return nil, nil, fmt.Errorf("conflicting shares: shares %d and %d are both group %d member %d (identifier %d), but differ - were shares from different backups mixed, or tampered with?",
+				first, positions[i], s.GroupIndex+1, s.MemberIndex+1, s.Identifier)
+		}
+		claimed[key] = positions[i]
+	}
+	return deduped, positions, nil
+}
+
+// shareSet is the shares from a single SLIP39 backup, i.e. with the same
+// identifier, extendable flag, iteration exponent, and group parameters
+type shareSet struct {
+	params    slip39.ShareCommonParameters
+	mnemonics []string
+	positions []int // 1-based input positions
+	shares    []slip39.Share
+}
+
+// partitionShareSets splits mnemonics (whose input positions are in
+// positions) into share sets, in order of first appearance
+func partitionShareSets(mnemonics []string, positions []int) ([]*shareSet, error) {
+	sets := []*shareSet{}
+	index := map[slip39.ShareCommonParameters]*shareSet{}
+	for i, mnemonic := range mnemonics {
+		s, err := slip39.ParseShare(mnemonic)
+		if err != nil {
+			return nil, fmt.Errorf("parsing share %d: %w", positions[i], err)
+		}
+		set, ok := index[s.ShareCommonParameters]
+		if !ok {
+			set = &shareSet{params: s.ShareCommonParameters}
+			index[s.ShareCommonParameters] = set
+			sets = append(sets, set)
+		}
+		set.mnemonics = append(set.mnemonics, mnemonic)
+		set.positions = append(set.positions, positions[i])
+		set.shares = append(set.shares, s)
+	}
+	return sets, nil
+}
+
+// writeHeader outputs a description of ss, set n of total, and the group
+// structure its shares present to w
+func (ss *shareSet) writeHeader(w io.Writer, n, total int) {
+	attrs := []string{fmt.Sprintf("iteration exponent %d", ss.params.IterationExponent)}
+	if ss.params.Extendable != 0 {
+		attrs = append(attrs, "extendable")
+	}
+	fmt.Fprintf(w, "Share set %d of %d: identifier %d (%s) - %d of %d groups required\n",
+		n, total, ss.params.Identifier, strings.Join(attrs, ", "),
+		ss.params.GroupThreshold, ss.params.GroupCount)
+
+	thresholds := map[int]int{}
+	members := map[int][]int{}
+	for _, s := range ss.shares {
+		thresholds[s.GroupIndex] = s.MemberThreshold
+		members[s.GroupIndex] = append(members[s.GroupIndex], s.MemberIndex+1)
+	}
+	groups := make([]int, 0, len(members))
+	for g := range members {
+		groups = append(groups, g)
+		sort.Ints(members[g])
+	}
+	sort.Ints(groups)
+	for _, g := range groups {
+		have := make([]string, len(members[g]))
+		for i, m := range members[g] {
+			have[i] = fmt.Sprint(m)
+		}
+		required, present := "members", "members"
+		if thresholds[g] == 1 {
+			required = "member"
+		}
+		if len(have) == 1 {
+			present = "member"
+		}
+		fmt.Fprintf(w, "  group %d: %d %s required, %s %s present\n",
+			g+1, thresholds[g], required, present, strings.Join(have, ", "))
+	}
+}
+
+// runShareSets outputs a header for each set in sets, and runs fn on it,
+// reporting (but continuing past) any sets that fail
+func runShareSets(ctx *Context, sets []*shareSet, fn func(*shareSet) error) error {
+	failed := 0
+	for i, set := range sets {
+		if i > 0 {
+			fmt.Fprintln(ctx.writer)
+		}
+		set.writeHeader(ctx.writer, i+1, len(sets))
+		if err := fn(set); err != nil {
+			failed++
+			fmt.Fprintf(ctx.writer, "%s %s\n", color.RedString(crossGlyph), err.Error())
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d share sets failed", failed, len(sets))
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/gavincarr/go-slip39"
+	"golang.org/x/term"
+)
+
+// clearScreenSeq is the ANSI sequence to move the cursor home, and clear the
+// screen and scrollback
+const clearScreenSeq = "\033[H\033[2J\033[3J"
+
+// shareDisplayColumns is the number of columns used to display share words
+const shareDisplayColumns = 4
+
+// terminal handles interactive prompts using a Context's reader and writer
+type terminal struct {
+	in     *bufio.Reader
+	out    io.Writer
+	closer io.Closer
+	// file is the underlying input file, if any, for turning off echo
+	file *os.File
+}
+
+// newTerminal returns a terminal for ctx, reading from stdin if ctx has no
+// reader
+func newTerminal(ctx *Context) *terminal {
+	reader := ctx.reader
+	if reader == nil {
+		reader = os.Stdin
+	}
+	t := &terminal{in: bufio.NewReader(reader), out: ctx.writer}
+	if file, ok := reader.(*os.File); ok {
+		t.file = file
+	}
+	return t
+}
+
+// openTTY returns a terminal reading from the controlling terminal, for
+// prompting when stdin has already been used for input. If ctx has a reader
+// (i.e. in tests), that is used instead.
+func openTTY(ctx *Context) (*terminal, error) {
+	if ctx.reader != nil {
+		return newTerminal(ctx), nil
+	}
+	path := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		path = "CONIN$"
+	}
+	tty, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening terminal for prompts (pass the seed as arguments instead?): %w", err)
+	}
+	return &terminal{in: bufio.NewReader(tty), out: ctx.writer, closer: tty, file: tty}, nil
+}
+
+// close closes the terminal's input, if it was opened by openTTY
+func (t *terminal) close() {
+	if t.closer != nil {
+		t.closer.Close()
+	}
+}
+
+// readLine outputs prompt and returns the line entered, trimmed
+func (t *terminal) readLine(prompt string) (string, error) {
+	fmt.Fprint(t.out, prompt)
+	line, err := t.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return "", errors.New("input ended unexpectedly")
+		}
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// readSecretLine outputs prompt and returns the line entered, trimmed, with
+// echo turned off if the input is a terminal
+func (t *terminal) readSecretLine(prompt string) (string, error) {
+	if t.file == nil || !term.IsTerminal(int(t.file.Fd())) || t.in.Buffered() > 0 {
+		return t.readLine(prompt)
+	}
+	fmt.Fprint(t.out, prompt)
+	line, err := term.ReadPassword(int(t.file.Fd()))
+	defer wipeBytes(line)
+	fmt.Fprintln(t.out)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(line)), nil
+}
+
+// readDefault outputs prompt (with def) and returns the line entered, or def
+// if the line is empty
+func (t *terminal) readDefault(prompt, def string) (string, error) {
+	line, err := t.readLine(fmt.Sprintf("%s [%s]: ", prompt, def))
+	if err != nil || line == "" {
+		return def, err
+	}
+	return line, nil
+}
+
+// readInt prompts for an integer between low and high, re-prompting until one
+// is entered
+func (t *terminal) readInt(prompt string, def, low, high int) (int, error) {
+	for {
+		line, err := t.readDefault(prompt, strconv.Itoa(def))
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.Atoi(line)
+		if err == nil && n >= low && n <= high {
+			return n, nil
+		}
+		fmt.Fprintf(t.out, "%s please enter a number from %d to %d\n",
+			color.YellowString("Warning:"), low, high)
+	}
+}
+
+// confirm prompts for a yes/no answer, returning def if the line is empty
+func (t *terminal) confirm(prompt string, def bool) (bool, error) {
+	options := "y/N"
+	if def {
+		options = "Y/n"
+	}
+	for {
+		line, err := t.readLine(fmt.Sprintf("%s [%s]: ", prompt, options))
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(line) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
+
+// waitForEnter outputs prompt and waits for the user to press Enter
+func (t *terminal) waitForEnter(prompt string) error {
+	_, err := t.readLine(prompt)
+	return err
+}
+
+// clear clears the screen and scrollback
+func (t *terminal) clear() {
+	fmt.Fprint(t.out, clearScreenSeq)
+}
+
+// shareDisplay is a SLIP39 share with the metadata to display alongside it
+type shareDisplay struct {
+	wallet          string
+	group           int
+	groupName       string
+	groupCount      int
+	groupThreshold  int
+	member          int
+	custodian       string
+	memberCount     int
+	memberThreshold int
+	mnemonic        string
+}
+
+// newShareDisplays returns shareDisplays for each of the shares in
+// shareGroups, generated using policy
+func newShareDisplays(
+	wallet string,
+	policy *sharePolicy,
+	shareGroups slip39.ShareGroups,
+) []shareDisplay {
+	displays := []shareDisplay{}
+	for g, shares := range shareGroups {
+		for m, mnemonic := range shares {
+			custodian := ""
+			if m < len(policy.groups[g].custodians) {
+				custodian = policy.groups[g].custodians[m].name
+			}
+			displays = append(displays, shareDisplay{
+				wallet:          wallet,
+				group:           g + 1,
+				groupName:       policy.groups[g].name,
+				groupCount:      len(shareGroups),
+				groupThreshold:  policy.groupThreshold,
+				member:          m + 1,
+				custodian:       custodian,
+				memberCount:     len(shares),
+				memberThreshold: policy.groups[g].threshold,
+				mnemonic:        mnemonic,
+			})
+		}
+	}
+	return displays
+}
+
+// title returns the label to record with sd, in the form recommended by the
+// recipes e.g. "Cicero, Group 2, Share 3, 3of5, Threshold 2", or with a named
+// group and custodian "Cicero, Group 2 (vault), Share 3 (Erin), 3of5, Threshold 2"
+func (sd shareDisplay) title() string {
+	parts := []string{}
+	if sd.wallet != "" {
+		parts = append(parts, sd.wallet)
+	}
+	switch {
+	case sd.groupCount > 1 && sd.groupName != "":
+		parts = append(parts, fmt.Sprintf("Group %d (%s)", sd.group, sd.groupName))
+	case sd.groupCount > 1:
+		parts = append(parts, fmt.Sprintf("Group %d", sd.group))
+	case sd.groupName != "":
+		parts = append(parts, sd.groupName)
+	}
+	switch {
+	case sd.memberCount > 1 && sd.custodian != "":
+		parts = append(parts, fmt.Sprintf("Share %d (%s)", sd.member, sd.custodian))
+	case sd.memberCount > 1:
+		parts = append(parts, fmt.Sprintf("Share %d", sd.member))
+	case sd.custodian != "":
+		parts = append(parts, sd.custodian)
+	}
+	parts = append(parts, fmt.Sprintf("%dof%d", sd.memberThreshold, sd.memberCount))
+	if sd.groupCount > 1 {
+		parts = append(parts, fmt.Sprintf("Threshold %d", sd.groupThreshold))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// displayShare clears the screen and displays sd alone, waits for the user to
+// confirm they have recorded it, and then clears the screen again
+func (t *terminal) displayShare(sd shareDisplay, index, total int) error {
+	return t.displayWords(
+		fmt.Sprintf("Share %d of %d: %s", index, total, sd.title()), sd.mnemonic)
+}
+
+// displayWords clears the screen and displays heading and the words of
+// mnemonic in large numbered columns, waits for the user to confirm they have
+// recorded them, and then clears the screen again
+func (t *terminal) displayWords(heading, mnemonic string) error {
+	t.clear()
+	bold := color.New(color.Bold)
+	fmt.Fprintf(t.out, "%s\n\n", bold.Sprint(heading))
+
+	words := strings.Fields(mnemonic)
+	rows := (len(words) + shareDisplayColumns - 1) / shareDisplayColumns
+	for r := range rows {
+		var sb strings.Builder
+		for c := range shareDisplayColumns {
+			i := c*rows + r
+			if i >= len(words) {
+				break
+			}
+			fmt.Fprintf(&sb, "  %2d. %s", i+1,
+				bold.Sprintf("%-10s", strings.ToUpper(words[i])))
+		}
+		fmt.Fprintln(t.out, sb.String())
+	}
+	fmt.Fprintln(t.out)
+
+	err := t.waitForEnter("Record these words, then press Enter to clear the screen...")
+	t.clear()
+	return err
+}
+
+// retypeShare prompts the user to type sd back in (without echo, where
+// possible), re-prompting with the positions of any mismatched words until
+// it matches
+func (t *terminal) retypeShare(sd shareDisplay, index, total int) error {
+	expected := strings.Fields(sd.mnemonic)
+	for {
+		line, err := t.readSecretLine(fmt.Sprintf(
+			"Type back share %d of %d (%s), all %d words: ",
+			index, total, sd.title(), len(expected)))
+		if err != nil {
+			return err
+		}
+		lines, err := tokenizeInput(line)
+		if err != nil {
+			fmt.Fprintf(t.out, "%s %s\n", color.YellowString("Warning:"), err.Error())
+			continue
+		}
+		tokens := flattenTokens(lines)
+		mismatches := []string{}
+		for i := range max(len(expected), len(tokens)) {
+			if i >= len(expected) || i >= len(tokens) || tokens[i].word != expected[i] {
+				mismatches = append(mismatches, strconv.Itoa(i+1))
+			}
+		}
+		if len(mismatches) == 0 {
+			fmt.Fprintf(t.out, "%s Share %d %s\n",
+				color.GreenString(tickGlyph), index, color.GreenString("matches"))
+			return nil
+		}
+		if len(tokens) != len(expected) {
+			fmt.Fprintf(t.out, "%s expected %d words, got %d - please try again\n",
+				color.RedString(crossGlyph), len(expected), len(tokens))
+			continue
+		}
+		words, verb := "words", "do"
+		if len(mismatches) == 1 {
+			words, verb = "word", "does"
+		}
+		fmt.Fprintf(t.out, "%s %s %s %s not match - please check and try again\n",
+			color.RedString(crossGlyph), words, strings.Join(mismatches, ", "), verb)
+	}
+}
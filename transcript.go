@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/gavincarr/go-slip39"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// verify-transcript only shows expected words if there are at most one
+// mismatch per transcriptRevealRatio words
+const transcriptRevealRatio = 8
+
+// transcriptMnemonic is a mnemonic from the original in a verify-transcript
+// comparison, with its labelling position
+type transcriptMnemonic struct {
+	group  int
+	member int
+	words  []string
+}
+
+// transcriptDiff is a single word mismatch between an original and a transcript
+type transcriptDiff struct {
+	position string
+	expected string
+	got      string
+}
+
+// parseTranscriptOriginal parses input as either a BIP39 mnemonic or a set of
+// SLIP39 shares, returning the mnemonics ordered and numbered as the bl and sl
+// commands label them, and whether the original is a BIP39 mnemonic
+func parseTranscriptOriginal(input string) ([]transcriptMnemonic, bool, error) {
+	lines, err := tokenizeInput(input)
+	if err != nil {
+		return nil, false, fmt.Errorf("original: %w", err)
+	}
+	tokens := flattenTokens(lines)
+	if len(tokens) == 0 {
+		return nil, false, errors.New("original: no mnemonic words found")
+	}
+	if checkBip39Words(tokens) == nil && bip39.IsMnemonicValid(joinTokenWords(tokens)) {
+		words := make([]string, len(tokens))
+		for i, t := range tokens {
+			words[i] = t.word
+		}
+		return []transcriptMnemonic{{group: 1, member: 1, words: words}}, true, nil
+	}
+
+	mnemonics, err := parseShareMnemonics(input)
+	if err != nil {
+		return nil, false, fmt.Errorf("original is not a valid BIP-39 mnemonic or set of SLIP-39 shares: %w", err)
+	}
+	shares := make([]slip39.Share, len(mnemonics))
+	for i, mnemonic := range mnemonics {
+		shares[i], err = slip39.ParseShare(mnemonic)
+		if err != nil {
+			return nil, false, fmt.Errorf("original share %d: %w", i+1, err)
+		}
+	}
+
+	// Number groups and members as sl does, by group index, and then by
+	// input order within each group
+	order := make([]int, len(shares))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return shares[order[i]].GroupIndex < shares[order[j]].GroupIndex
+	})
+	originals := make([]transcriptMnemonic, 0, len(shares))
+	group, member := 0, 0
+	for i, idx := range order {
+		if i == 0 || shares[idx].GroupIndex != shares[order[i-1]].GroupIndex {
+			group++
+			member = 0
+		}
+		member++
+		words, err := shares[idx].Words()
+		if err != nil {
+			return nil, false, err
+		}
+		originals = append(originals, transcriptMnemonic{
+			group:  group,
+			member: member,
+			words:  words,
+		})
+	}
+	return originals, false, nil
+}
+
+// transcriptPosition describes the position of word w in m e.g. "share 2
+// word 5", with "group g" included if groups is true
+func transcriptPosition(m transcriptMnemonic, w int, bip, groups bool) string {
+	switch {
+	case bip:
+		return fmt.Sprintf("word %d", w)
+	case groups:
+		return fmt.Sprintf("group %d share %d word %d", m.group, m.member, w)
+	default:
+		return fmt.Sprintf("share %d word %d", m.member, w)
+	}
+}
+
+// diffTranscript compares the words in transcript with originals, returning
+// a transcriptDiff for each mismatched, missing, or unexpected word. If all
+// transcript words are labelled, words are matched by label using labels
+// (a label scheme), and otherwise they are compared in order.
+func diffTranscript(originals []transcriptMnemonic, bip bool, transcript, labels string) ([]transcriptDiff, int, error) {
+	lines, err := tokenizeInput(transcript)
+	if err != nil {
+		return nil, 0, fmt.Errorf("transcript: %w", err)
+	}
+	tokens := flattenTokens(lines)
+	if len(tokens) == 0 {
+		return nil, 0, errors.New("transcript: no mnemonic words found")
+	}
+	labelled := true
+	for _, t := range tokens {
+		labelled = labelled && t.label != ""
+	}
+
+	groups := false
+	total := 0
+	for _, m := range originals {
+		groups = groups || m.group > 1
+		total += len(m.words)
+	}
+
+	diffs := []transcriptDiff{}
+	if !labelled {
+		i := 0
+		for _, m := range originals {
+			for w, expected := range m.words {
+				got := "(missing)"
+				if i < len(tokens) {
+					got = tokens[i].word
+				}
+				i++
+				if got != expected {
+					diffs = append(diffs, transcriptDiff{
+						position: transcriptPosition(m, w+1, bip, groups),
+						expected: expected,
+						got:      got,
+					})
+				}
+			}
+		}
+		for _, t := range tokens[min(i, len(tokens)):] {
+			diffs = append(diffs, transcriptDiff{
+				position: fmt.Sprintf("extra word (%s)", t.location()),
+				expected: "(none)",
+				got:      t.word,
+			})
+		}
+		return diffs, total, nil
+	}
+
+	var lt *labelTemplate
+	if bip {
+		lt, err = resolveLabelScheme(labelSchemeOrDefault(labels), true, 1)
+	} else {
+		lt, err = resolveInputLabelScheme(labelSchemeOrDefault(labels), tokens)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	collated, err := collateLabelledWords(tokens, lt)
+	if err != nil {
+		return nil, 0, fmt.Errorf("transcript: %w", err)
+	}
+	index := map[[2]int]*labelledMnemonic{}
+	for _, lm := range collated {
+		index[[2]int{lm.group, lm.member}] = lm
+	}
+
+	for _, m := range originals {
+		lm := index[[2]int{m.group, m.member}]
+		delete(index, [2]int{m.group, m.member})
+		for w, expected := range m.words {
+			pos := labelPosition{group: m.group, member: m.member, word: w + 1}
+			position := fmt.Sprintf("%s (label %q)",
+				transcriptPosition(m, w+1, bip, groups), lt.format(pos))
+			got := "(missing)"
+			if lm != nil {
+				if t, ok := lm.words[w+1]; ok {
+					got = t.word
+				}
+				for _, dup := range lm.duplicates[w+1] {
+					if dup.word != got {
+						got = fmt.Sprintf("%s (duplicate label on line %d: %s)",
+							got, dup.line, dup.word)
+					}
+				}
+			}
+			if got != expected {
+				diffs = append(diffs, transcriptDiff{
+					position: position,
+					expected: expected,
+					got:      got,
+				})
+			}
+		}
+		if lm == nil {
+			continue
+		}
+		for w := len(m.words) + 1; w <= lm.length(); w++ {
+			if t, ok := lm.words[w]; ok {
+				diffs = append(diffs, transcriptDiff{
+					position: fmt.Sprintf("extra word (label %q)", t.label),
+					expected: "(none)",
+					got:      t.word,
+				})
+			}
+		}
+	}
+
+	// Any remaining labelled mnemonics aren't in the original
+	for _, lm := range collated {
+		if _, ok := index[[2]int{lm.group, lm.member}]; ok {
+			diffs = append(diffs, transcriptDiff{
+				position: fmt.Sprintf("extra %s", lm.name(groups)),
+				expected: "(none)",
+				got:      fmt.Sprintf("%d words", len(lm.words)),
+			})
+		}
+	}
+
+	return diffs, total, nil
+}
+
+func (cmd VerifyTranscriptCmd) Run(ctx *Context) error {
+	data, err := readSecretFileString(cmd.Original)
+	if err != nil {
+		return fmt.Errorf("reading original: %w", err)
+	}
+	originals, bip, err := parseTranscriptOriginal(data)
+	if err != nil {
+		return err
+	}
+
+	var transcript string
+	if cmd.Transcript != "" {
+		transcript, err = readSecretFileString(cmd.Transcript)
+		if err != nil {
+			return fmt.Errorf("reading transcript: %w", err)
+		}
+	} else {
+		transcript, err = readStdin(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	diffs, total, err := diffTranscript(originals, bip, transcript, cmd.Labels)
+	if err != nil {
+		return err
+	}
+
+	if len(diffs) == 0 {
+		fmt.Fprintf(ctx.writer, "%s Transcript %s - all %d words match\n",
+			color.GreenString(tickGlyph), color.GreenString("matches"), total)
+		return nil
+	}
+
+	// Only mismatched words are ever output, never the full original, and
+	// if there are too many mismatches (e.g. the wrong transcript, or one
+	// badly truncated) the expected words are hidden too
+	reveal := len(diffs) <= total/transcriptRevealRatio
+	if !reveal {
+		fmt.Fprintf(ctx.writer, "%s too many mismatches - hiding expected words\n",
+			color.YellowString("Warning:"))
+	}
+	for _, d := range diffs {
+		expected := d.expected
+		if !reveal && expected != "(none)" {
+			expected = "(hidden)"
+		}
+		fmt.Fprintf(ctx.writer, "%s %s: expected %q, got %q\n",
+			color.RedString(crossGlyph), d.position, expected, d.got)
+	}
+	plural := "es"
+	if len(diffs) == 1 {
+		plural = ""
+	}
+	return fmt.Errorf("transcript does not match - %d mismatch%s in %d words",
+		len(diffs), plural, total)
+}